@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/byte-sat/llum-tools/tools"
+)
+
+type ErrorCode string
+
+const (
+	ErrInvalidRequest   ErrorCode = "invalid_request"
+	ErrUnknownTool      ErrorCode = "unknown_tool"
+	ErrInvalidArguments ErrorCode = "invalid_arguments"
+	ErrToolError        ErrorCode = "tool_error"
+	ErrTimeout          ErrorCode = "timeout"
+	ErrPanic            ErrorCode = "panic"
+)
+
+// Result is the envelope every tool invocation is answered with.
+type Result struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	OK     bool       `json:"ok"`
+	Result any        `json:"result,omitempty"`
+	Error  *ToolError `json:"error,omitempty"`
+	Meta   *Meta      `json:"meta,omitempty"`
+}
+
+// Meta describes how a call was handled, apart from its outcome.
+type Meta struct {
+	// Repairs lists the fixes applied to malformed arguments.
+	Repairs []tools.Repair `json:"repairs,omitempty"`
+}
+
+type ToolError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Details any       `json:"details,omitempty"`
+}
+
+func newResult(ctx context.Context, id, name string, out any, err error) Result {
+	if err == nil {
+		return Result{ID: id, Name: name, OK: true, Result: out}
+	}
+
+	te := &ToolError{Code: ErrToolError, Message: err.Error()}
+	var perr *tools.PanicError
+	switch {
+	case errors.Is(err, tools.ErrNotFound):
+		te.Code = ErrUnknownTool
+	case errors.Is(err, tools.ErrInvalidArguments):
+		te.Code = ErrInvalidArguments
+		var aerr *tools.ArgumentError
+		if errors.As(err, &aerr) {
+			te.Details = aerr.Violations
+		}
+	case errors.As(err, &perr):
+		log.Printf("tool %s panicked: %v\n%s", name, perr.Value, perr.Stack)
+		te.Code = ErrPanic
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(ctx.Err(), context.DeadlineExceeded):
+		te.Code = ErrTimeout
+	}
+
+	return Result{ID: id, Name: name, Error: te}
+}
+
+// Status returns the HTTP status code matching the result.
+func (r *Result) Status() int {
+	if r.OK {
+		return http.StatusOK
+	}
+
+	switch r.Error.Code {
+	case ErrInvalidRequest:
+		return http.StatusBadRequest
+	case ErrUnknownTool:
+		return http.StatusNotFound
+	case ErrInvalidArguments:
+		return http.StatusUnprocessableEntity
+	case ErrTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/byte-sat/llum-tools/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+type JobRepo struct {
+	*jobs.Store
+}
+
+func (jr *JobRepo) Routes(r chi.Router) {
+	r.Get("/{id}", jr.GetJob)
+	r.Get("/{id}/result", jr.GetResult)
+	r.Post("/{id}/cancel", jr.CancelJob)
+	r.Delete("/{id}", jr.DeleteJob)
+}
+
+func (jr *JobRepo) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jr.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+
+	job.Result = nil
+	json.NewEncoder(w).Encode(job)
+}
+
+func (jr *JobRepo) GetResult(w http.ResponseWriter, r *http.Request) {
+	job, err := jr.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+
+	switch {
+	case job.Status == jobs.Running:
+		http.Error(w, "job is still running", http.StatusConflict)
+	case job.Status == jobs.Canceled:
+		http.Error(w, "job was canceled", http.StatusGone)
+	case job.Result == nil:
+		http.Error(w, job.Error, http.StatusInternalServerError)
+	default:
+		var res Result
+		if err := json.Unmarshal(job.Result, &res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeResult(w, res)
+	}
+}
+
+func (jr *JobRepo) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jr.Cancel(chi.URLParam(r, "id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+
+	job.Result = nil
+	json.NewEncoder(w).Encode(job)
+}
+
+func (jr *JobRepo) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	if err := jr.Delete(chi.URLParam(r, "id")); err != nil {
+		jobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func jobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, jobs.ErrFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,206 @@
+// Package jobs runs long running tool invocations in the background.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrFinished = errors.New("job already finished")
+)
+
+type Status string
+
+const (
+	Running  Status = "running"
+	Done     Status = "done"
+	Failed   Status = "failed"
+	Canceled Status = "canceled"
+)
+
+type Job struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Status  Status          `json:"status"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Created time.Time       `json:"created"`
+	Updated time.Time       `json:"updated"`
+}
+
+// Finished reports whether the job is no longer running.
+func (j *Job) Finished() bool { return j.Status != Running }
+
+// Persister stores jobs. Implementations must be safe for concurrent use.
+type Persister interface {
+	Save(*Job) error
+	// Load returns ErrNotFound if there is no job with the given id.
+	Load(id string) (*Job, error)
+	Delete(id string) error
+}
+
+// Func is the work done by a job. The context is canceled when the job is.
+// The returned value is kept as the job result even if the job fails.
+type Func func(ctx context.Context) (any, error)
+
+// DefaultTimeout is how long jobs may run unless Store.Timeout is set.
+const DefaultTimeout = 10 * time.Minute
+
+var errTimeout = errors.New("job timed out")
+
+// Store starts jobs and keeps track of their state.
+type Store struct {
+	// Timeout is how long a job may run before its context is canceled and
+	// it fails, DefaultTimeout if zero.
+	Timeout time.Duration
+
+	p Persister
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewStore creates a Store backed by p. If p is nil, jobs are kept in
+// memory.
+func NewStore(p Persister) *Store {
+	if p == nil {
+		p = NewMemory()
+	}
+	return &Store{
+		p:       p,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start runs fn in the background and returns the created job.
+func (s *Store) Start(name string, fn Func) (*Job, error) {
+	now := time.Now()
+	job := &Job{
+		ID:      newID(),
+		Name:    name,
+		Status:  Running,
+		Created: now,
+		Updated: now,
+	}
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeoutCause(context.Background(), timeout, errTimeout)
+
+	// the job can be canceled as soon as it's saved
+	s.mu.Lock()
+	s.cancels[job.ID] = cancel
+	err := s.p.Save(job)
+	if err != nil {
+		delete(s.cancels, job.ID)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	cp := *job
+	go s.run(ctx, &cp, fn)
+	return job, nil
+}
+
+func (s *Store) run(ctx context.Context, job *Job, fn Func) {
+	res, err := call(ctx, fn)
+	if err == nil && context.Cause(ctx) == errTimeout {
+		err = errTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.cancels[job.ID]
+	if !ok {
+		// the job was canceled, Cancel already saved its state
+		return
+	}
+	cancel()
+	delete(s.cancels, job.ID)
+
+	job.Status = Done
+	if res != nil {
+		var merr error
+		if job.Result, merr = json.Marshal(res); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	if err != nil {
+		job.Status = Failed
+		job.Error = err.Error()
+	}
+	job.Updated = time.Now()
+	if err := s.p.Save(job); err != nil {
+		log.Printf("jobs: saving job %s: %v", job.ID, err)
+	}
+}
+
+// call runs fn, turning panics into errors as nothing would recover them.
+func call(ctx context.Context, fn Func) (res any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("jobs: job panicked: %v\n%s", v, debug.Stack())
+			res, err = nil, fmt.Errorf("job panicked: %v", v)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Get returns the job with the given id.
+func (s *Store) Get(id string) (*Job, error) {
+	return s.p.Load(id)
+}
+
+// Cancel stops a running job.
+func (s *Store) Cancel(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.p.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cancel, ok := s.cancels[id]
+	if !ok {
+		return job, ErrFinished
+	}
+	cancel()
+	delete(s.cancels, id)
+
+	job.Status = Canceled
+	job.Updated = time.Now()
+	if err := s.p.Save(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Delete cancels the job if it is still running and removes it.
+func (s *Store) Delete(id string) error {
+	if _, err := s.Cancel(id); err != nil && err != ErrFinished {
+		return err
+	}
+	return s.p.Delete(id)
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is how long finished jobs are kept in Memory unless
+// Memory.MaxAge is set.
+const DefaultMaxAge = time.Hour
+
+// Memory is an in-process Persister.
+type Memory struct {
+	// MaxAge is how long finished jobs are kept after they were last
+	// updated, DefaultMaxAge if zero.
+	MaxAge time.Duration
+
+	mu    sync.RWMutex
+	jobs  map[string]Job
+	swept time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{jobs: make(map[string]Job)}
+}
+
+func (m *Memory) Save(job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = *job
+	m.sweep()
+	return nil
+}
+
+func (m *Memory) Load(id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok || m.expired(&job, time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+func (m *Memory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, id)
+	return nil
+}
+
+func (m *Memory) maxAge() time.Duration {
+	if m.MaxAge == 0 {
+		return DefaultMaxAge
+	}
+	return m.MaxAge
+}
+
+func (m *Memory) expired(job *Job, now time.Time) bool {
+	return job.Finished() && now.Sub(job.Updated) > m.maxAge()
+}
+
+// sweep drops the expired jobs, at most a few times per MaxAge.
+func (m *Memory) sweep() {
+	now := time.Now()
+	if now.Sub(m.swept) < m.maxAge()/10 {
+		return
+	}
+	m.swept = now
+
+	for id, job := range m.jobs {
+		if m.expired(&job, now) {
+			delete(m.jobs, id)
+		}
+	}
+}
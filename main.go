@@ -4,12 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"time"
 
+	"github.com/byte-sat/llum-tools/jobs"
+	"github.com/byte-sat/llum-tools/provider"
+	"github.com/byte-sat/llum-tools/schema"
 	"github.com/byte-sat/llum-tools/tools"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -19,6 +24,9 @@ import (
 )
 
 var addr = flag.String("addr", ":3333", "address to listen on")
+var maxResultSize = flag.Int("max-result-size", 0, "truncate tool results larger than this many bytes, 0 for no limit")
+var repairArgs = flag.Bool("repair-args", false, "repair malformed tool call arguments")
+var openAIStrict = flag.Bool("openai-strict", false, "make tool schemas compatible with OpenAI strict mode")
 
 //go:generate go run github.com/noonien/codoc/cmd/codoc@latest -out tools_codoc.go -pkg main .
 
@@ -36,15 +44,36 @@ func Whois(domain string) (string, error) {
 func main() {
 	flag.Parse()
 
-	inj, err := tools.Inject(context.Background, ChatID(""))
+	inj, err := tools.Inject(
+		context.Background,
+		ChatID(""),
+		func() tools.Stream { return tools.Discard },
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	repo, err := tools.New(inj, GetCID, Whois)
+	fns := []any{GetCID, Whois}
+	if *maxResultSize > 0 {
+		fns = append([]any{tools.MaxResultSize(*maxResultSize), tools.Paginate()}, fns...)
+	}
+
+	if *openAIStrict {
+		fns = append([]any{tools.OpenAIStrict()}, fns...)
+	}
+
+	repo, err := tools.New(inj, fns...)
 	if err != nil {
 		log.Fatal(err)
 	}
+	for name, err := range repo.NonStrict() {
+		log.Printf("tool %s can't be made strict: %v", name, err)
+	}
+	for _, d := range schema.Dialects {
+		for name, err := range repo.Unsupported(d) {
+			log.Printf("tool %s left out of the %s schema: %v", name, d, err)
+		}
+	}
 	r := chi.NewRouter()
 
 	// A good base middleware stack
@@ -64,9 +93,11 @@ func main() {
 		AllowedHeaders: []string{"*"},
 	}))
 
-	tr := &ToolRepo{repo}
+	tr := &ToolRepo{repo, jobs.NewStore(nil)}
 	r.Get("/tool_schema", tr.GetToolSchema)
 	r.Post("/tool", tr.InvokeTool)
+	r.Post("/tool_calls", tr.InvokeToolCalls)
+	r.Route("/jobs", (&JobRepo{tr.jobs}).Routes)
 
 	log.Println("listening on", *addr)
 	if err := http.ListenAndServe(*addr, r); err != nil {
@@ -76,12 +107,38 @@ func main() {
 
 type ToolRepo struct {
 	*tools.Repo
+	jobs *jobs.Store
+}
+
+// dialect returns the schema dialect chosen by the format parameter of r,
+// OpenAI by default.
+func dialect(r *http.Request) (schema.Dialect, error) {
+	d := schema.OpenAI
+	if format := r.URL.Query().Get("format"); format != "" {
+		d = schema.Dialect(format)
+	}
+	if !slices.Contains(schema.Dialects, d) {
+		return "", fmt.Errorf("unknown format %q", d)
+	}
+	return d, nil
 }
 
 func (tr *ToolRepo) GetToolSchema(w http.ResponseWriter, r *http.Request) {
+	dialect, err := dialect(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defs, err := tr.SchemaFor(dialect)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	err := enc.Encode(tr.Schema())
+	err = enc.Encode(defs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -92,24 +149,141 @@ type ChatID string
 
 func (tr *ToolRepo) InvokeTool(w http.ResponseWriter, r *http.Request) {
 	var call struct {
-		ChatID ChatID         `json:"chat_id"`
-		Name   string         `json:"name"`
-		Args   map[string]any `json:"arguments"`
+		ID     string          `json:"id"`
+		ChatID ChatID          `json:"chat_id"`
+		Name   string          `json:"name"`
+		Args   json.RawMessage `json:"arguments"`
 	}
-	if err := json.NewDecoder(io.TeeReader(r.Body, os.Stdout)).Decode(&call); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var repairs []tools.Repair
+	body, err := io.ReadAll(io.TeeReader(r.Body, os.Stdout))
+	if err == nil {
+		err = json.Unmarshal(body, &call)
+		if err != nil && *repairArgs {
+			body, repairs = tools.RepairJSON(body)
+			if json.Unmarshal(body, &call) == nil {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		writeResult(w, Result{
+			ID:    middleware.GetReqID(r.Context()),
+			Error: &ToolError{Code: ErrInvalidRequest, Message: err.Error()},
+		})
+		return
+	}
+	if call.ID == "" {
+		call.ID = middleware.GetReqID(r.Context())
+	}
+	if *repairArgs {
+		var more []tools.Repair
+		call.Args, more = tools.RepairArguments(call.Args)
+		repairs = append(repairs, more...)
+	}
+	var meta *Meta
+	if len(repairs) > 0 {
+		meta = &Meta{Repairs: repairs}
+	}
+
+	if tr.IsAsync(call.Name) {
+		job, err := tr.jobs.Start(call.Name, func(ctx context.Context) (any, error) {
+			inj, _ := tools.Inject(
+				func() context.Context { return ctx },
+				call.ChatID,
+			)
+			out, err := tr.InvokeJSON(inj, call.Name, call.Args)
+			res := newResult(ctx, call.ID, call.Name, out, err)
+			res.Meta = meta
+			return res, err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     call.ID,
+			"name":   call.Name,
+			"job_id": job.ID,
+			"status": job.Status,
+		})
 		return
 	}
 
 	ctx := r.Context()
+	var stream tools.Stream = tools.Discard
+	es := newEventStream(w, r)
+	if es != nil {
+		stream = es
+	}
 	inj, _ := tools.Inject(
 		func() context.Context { return ctx },
 		call.ChatID,
+		func() tools.Stream { return stream },
 	)
-	out, err := tr.Invoke(inj, call.Name, call.Args)
+	out, err := tr.InvokeJSON(inj, call.Name, call.Args)
+	res := newResult(ctx, call.ID, call.Name, out, err)
+	res.Meta = meta
+	if es != nil {
+		es.send("result", res)
+		return
+	}
+	writeResult(w, res)
+}
+
+// InvokeToolCalls runs the tool calls of a model response, in the format
+// chosen by the format parameter, and answers with their results in the
+// same format. Async tools are run inline, as providers have no jobs.
+func (tr *ToolRepo) InvokeToolCalls(w http.ResponseWriter, r *http.Request) {
+	dialect, err := dialect(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(io.TeeReader(r.Body, os.Stdout))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	calls, err := provider.ParseCalls(dialect, body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	ctx := r.Context()
+	inj, _ := tools.Inject(
+		func() context.Context { return ctx },
+		ChatID(r.URL.Query().Get("chat_id")),
+	)
+	results := make([]provider.Result, len(calls))
+	for i, call := range calls {
+		args := call.Arguments
+		if *repairArgs {
+			args, _ = tools.RepairArguments(args)
+		}
+		out, err := tr.InvokeJSON(inj, call.Name, args)
+		res := newResult(ctx, call.ID, call.Name, out, err)
+
+		results[i] = provider.Result{ID: call.ID, Name: call.Name, Content: res.Result}
+		if !res.OK {
+			results[i].Content, results[i].IsError = res.Error, true
+		}
+	}
+
+	out, err := provider.FormatResults(dialect, results)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(out)
 }
+
+func writeResult(w http.ResponseWriter, res Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.Status())
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,331 @@
+// Package provider converts between the tool calls and results of model
+// providers and plain calls.
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// Call is a tool call requested by a model.
+type Call struct {
+	// ID identifies the call, to match its result. Gemini may not set it.
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// Result is the outcome of a Call.
+type Result struct {
+	ID   string
+	Name string
+	// Content is the output of the tool, or the error if IsError is set.
+	// Strings are sent as they are, other values are JSON encoded.
+	Content any
+	IsError bool
+}
+
+// ParseCalls returns the tool calls in data, a model response in the format
+// of dialect d. data may be a whole response, a message or the list of calls:
+//
+//   - OpenAI and Ollama: a chat completion, a message, or its tool_calls
+//   - OpenAI Responses: a response, or its output items
+//   - Anthropic: a message, or its content blocks
+//   - Gemini: a response, a content, or its parts
+//
+// Items which aren't tool calls are skipped.
+func ParseCalls(d schema.Dialect, data []byte) ([]Call, error) {
+	var calls []Call
+	var err error
+	switch d {
+	case schema.OpenAI, schema.Ollama:
+		calls, err = parseOpenAI(data)
+	case schema.OpenAIResponses:
+		calls, err = parseResponses(data)
+	case schema.Anthropic:
+		calls, err = parseAnthropic(data)
+	case schema.Gemini:
+		calls, err = parseGemini(data)
+	default:
+		return nil, fmt.Errorf("unknown dialect %q", d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s tool calls: %w", d, err)
+	}
+	return calls, nil
+}
+
+// FormatResults returns results in the format of dialect d, ready to be
+// added to the conversation:
+//
+//   - OpenAI and Ollama: tool role messages
+//   - OpenAI Responses: function_call_output items
+//   - Anthropic: tool_result content blocks
+//   - Gemini: functionResponse parts
+func FormatResults(d schema.Dialect, results []Result) (any, error) {
+	switch d {
+	case schema.OpenAI:
+		type message struct {
+			Role       string `json:"role"`
+			ToolCallID string `json:"tool_call_id"`
+			Content    string `json:"content"`
+		}
+		return formatEach(results, func(r Result, content string) message {
+			return message{Role: "tool", ToolCallID: r.ID, Content: content}
+		})
+
+	case schema.Ollama:
+		type message struct {
+			Role     string `json:"role"`
+			ToolName string `json:"tool_name"`
+			Content  string `json:"content"`
+		}
+		return formatEach(results, func(r Result, content string) message {
+			return message{Role: "tool", ToolName: r.Name, Content: content}
+		})
+
+	case schema.OpenAIResponses:
+		type item struct {
+			Type   string `json:"type"`
+			CallID string `json:"call_id"`
+			Output string `json:"output"`
+		}
+		return formatEach(results, func(r Result, content string) item {
+			return item{Type: "function_call_output", CallID: r.ID, Output: content}
+		})
+
+	case schema.Anthropic:
+		type block struct {
+			Type      string `json:"type"`
+			ToolUseID string `json:"tool_use_id"`
+			Content   string `json:"content"`
+			IsError   bool   `json:"is_error,omitempty"`
+		}
+		return formatEach(results, func(r Result, content string) block {
+			return block{Type: "tool_result", ToolUseID: r.ID, Content: content, IsError: r.IsError}
+		})
+
+	case schema.Gemini:
+		type response struct {
+			ID       string         `json:"id,omitempty"`
+			Name     string         `json:"name"`
+			Response map[string]any `json:"response"`
+		}
+		type part struct {
+			FunctionResponse response `json:"functionResponse"`
+		}
+		parts := make([]part, len(results))
+		for i, r := range results {
+			// the response must be an object
+			key := "output"
+			if r.IsError {
+				key = "error"
+			}
+			parts[i].FunctionResponse = response{
+				ID:       r.ID,
+				Name:     r.Name,
+				Response: map[string]any{key: r.Content},
+			}
+		}
+		return parts, nil
+	}
+
+	return nil, fmt.Errorf("unknown dialect %q", d)
+}
+
+func formatEach[T any](results []Result, format func(Result, string) T) ([]T, error) {
+	out := make([]T, len(results))
+	for i, r := range results {
+		content, err := contentString(r.Content)
+		if err != nil {
+			return nil, fmt.Errorf("result of %s: %w", r.Name, err)
+		}
+		out[i] = format(r, content)
+	}
+	return out, nil
+}
+
+func contentString(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func parseOpenAI(data []byte) ([]Call, error) {
+	type toolCall struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+			// a JSON encoded string for OpenAI, an object for Ollama
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	}
+	type message struct {
+		ToolCalls []toolCall `json:"tool_calls"`
+	}
+
+	var toolCalls []toolCall
+	if isArray(data) {
+		if err := json.Unmarshal(data, &toolCalls); err != nil {
+			return nil, err
+		}
+	} else {
+		var resp struct {
+			message
+			Choices []struct {
+				Message message `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		toolCalls = resp.ToolCalls
+		for _, c := range resp.Choices {
+			toolCalls = append(toolCalls, c.Message.ToolCalls...)
+		}
+	}
+
+	calls := make([]Call, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		if tc.Type != "" && tc.Type != "function" {
+			continue
+		}
+		calls = append(calls, Call{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: unquoteArguments(tc.Function.Arguments),
+		})
+	}
+	return calls, nil
+}
+
+func parseResponses(data []byte) ([]Call, error) {
+	type item struct {
+		Type      string          `json:"type"`
+		CallID    string          `json:"call_id"`
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+
+	var items []item
+	if isArray(data) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		var resp struct {
+			Output []item `json:"output"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		items = resp.Output
+	}
+
+	var calls []Call
+	for _, it := range items {
+		if it.Type != "function_call" {
+			continue
+		}
+		calls = append(calls, Call{ID: it.CallID, Name: it.Name, Arguments: unquoteArguments(it.Arguments)})
+	}
+	return calls, nil
+}
+
+func parseAnthropic(data []byte) ([]Call, error) {
+	type block struct {
+		Type  string          `json:"type"`
+		ID    string          `json:"id"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	}
+
+	var blocks []block
+	if isArray(data) {
+		if err := json.Unmarshal(data, &blocks); err != nil {
+			return nil, err
+		}
+	} else {
+		var msg struct {
+			Content []block `json:"content"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		blocks = msg.Content
+	}
+
+	var calls []Call
+	for _, b := range blocks {
+		if b.Type != "tool_use" {
+			continue
+		}
+		calls = append(calls, Call{ID: b.ID, Name: b.Name, Arguments: b.Input})
+	}
+	return calls, nil
+}
+
+func parseGemini(data []byte) ([]Call, error) {
+	type part struct {
+		FunctionCall *struct {
+			ID   string          `json:"id"`
+			Name string          `json:"name"`
+			Args json.RawMessage `json:"args"`
+		} `json:"functionCall"`
+	}
+	type content struct {
+		Parts []part `json:"parts"`
+	}
+
+	var parts []part
+	if isArray(data) {
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return nil, err
+		}
+	} else {
+		var resp struct {
+			content
+			Candidates []struct {
+				Content content `json:"content"`
+			} `json:"candidates"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		parts = resp.Parts
+		for _, c := range resp.Candidates {
+			parts = append(parts, c.Content.Parts...)
+		}
+	}
+
+	var calls []Call
+	for _, p := range parts {
+		if fc := p.FunctionCall; fc != nil {
+			calls = append(calls, Call{ID: fc.ID, Name: fc.Name, Arguments: fc.Args})
+		}
+	}
+	return calls, nil
+}
+
+func isArray(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '['
+}
+
+// unquoteArguments returns the JSON encoded in args if it is a string, so
+// both OpenAI string arguments and Ollama object arguments are accepted.
+// The contents of the string are returned even if they aren't valid JSON,
+// for them to be reported or repaired.
+func unquoteArguments(args json.RawMessage) json.RawMessage {
+	var s string
+	if err := json.Unmarshal(args, &s); err != nil {
+		return args
+	}
+	return json.RawMessage(s)
+}
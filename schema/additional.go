@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// AdditionalProperties is either a boolean allowing or forbidding
+// properties not listed in Properties, or the schema they must match.
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Definition
+}
+
+// AllowAdditional returns additional properties allowed or forbidden by a.
+func AllowAdditional(a bool) *AdditionalProperties {
+	return &AdditionalProperties{Allowed: a}
+}
+
+// AdditionalSchema returns additional properties matching def.
+func AdditionalSchema(def Definition) *AdditionalProperties {
+	return &AdditionalProperties{Allowed: true, Schema: &def}
+}
+
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(a.Allowed)
+}
+
+func (a *AdditionalProperties) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] != '{' {
+		a.Schema = nil
+		return json.Unmarshal(b, &a.Allowed)
+	}
+
+	a.Allowed = true
+	a.Schema = new(Definition)
+	return json.Unmarshal(b, a.Schema)
+}
@@ -1,5 +1,7 @@
 package schema
 
+import "encoding/json"
+
 type Type string
 
 const (
@@ -14,17 +16,107 @@ const (
 
 // Definition is a struct for describing a JSON Schema.
 type Definition struct {
+	// Ref references another schema, e.g. "#/$defs/Node".
+	Ref string `json:"$ref,omitempty"`
+	// Defs holds schemas that can be referenced from the rest of the schema.
+	Defs map[string]Definition `json:"$defs,omitempty"`
 	// Type specifies the data type of the schema.
 	Type Type `json:"type,omitempty"`
+	// Nullable allows the value to also be null. It is encoded by adding null to the type.
+	Nullable bool `json:"-"`
 	// Description is the description of the schema.
 	Description string `json:"description,omitempty"`
 	// Enum is used to restrict a value to a fixed set of values. It must be an array with at least
-	// one element, where each element is unique. You will probably only use this with strings.
-	Enum []string `json:"enum,omitempty"`
+	// one element, where each element is unique.
+	Enum []any `json:"enum,omitempty"`
 	// Properties describes the properties of an object, if the schema type is Object.
 	Properties Properties `json:"properties,omitempty"`
 	// Required specifies which properties are required, if the schema type is Object.
 	Required []string `json:"required,omitempty"`
 	// Items specifies which data type an array contains, if the schema type is Array.
 	Items *Definition `json:"items,omitempty"`
+	// AdditionalProperties specifies whether properties not listed in Properties are allowed,
+	// and which schema they must match, if the schema type is Object.
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+	// Const restricts a value to a single value.
+	Const any `json:"const,omitempty"`
+	// OneOf requires a value to match exactly one of the schemas.
+	OneOf []Definition `json:"oneOf,omitempty"`
+	// AnyOf requires a value to match at least one of the schemas.
+	AnyOf []Definition `json:"anyOf,omitempty"`
+	// Minimum and Maximum are the inclusive bounds of a number.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// ExclusiveMinimum and ExclusiveMaximum are the exclusive bounds of a number.
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	// MultipleOf restricts a number to multiples of the given value.
+	MultipleOf *float64 `json:"multipleOf,omitempty"`
+	// MinLength and MaxLength bound the length of a string, in characters.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+	// Pattern is a regular expression a string must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Format is the semantic format of a string, e.g. date-time or email.
+	Format string `json:"format,omitempty"`
+	// ContentEncoding is the encoding of binary data in a string, e.g. base64.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	// MinItems and MaxItems bound the length of an array.
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+	// UniqueItems requires all the elements of an array to be different.
+	UniqueItems bool `json:"uniqueItems,omitempty"`
+	// PatternProperties maps regular expressions to the schema of the properties whose name
+	// matches them, if the schema type is Object.
+	PatternProperties Properties `json:"patternProperties,omitempty"`
+}
+
+func (d Definition) MarshalJSON() ([]byte, error) {
+	type alias Definition
+	if !d.Nullable || d.Type == "" {
+		return json.Marshal(alias(d))
+	}
+
+	return json.Marshal(struct {
+		Type []Type `json:"type"`
+		alias
+	}{
+		Type:  []Type{d.Type, Null},
+		alias: alias(d),
+	})
+}
+
+func (d *Definition) UnmarshalJSON(b []byte) error {
+	type alias Definition
+	var def struct {
+		Type json.RawMessage `json:"type"`
+		*alias
+	}
+	def.alias = (*alias)(d)
+	if err := json.Unmarshal(b, &def); err != nil {
+		return err
+	}
+	if len(def.Type) == 0 {
+		return nil
+	}
+
+	if def.Type[0] != '[' {
+		return json.Unmarshal(def.Type, &d.Type)
+	}
+
+	var types []Type
+	if err := json.Unmarshal(def.Type, &types); err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t == Null {
+			d.Nullable = true
+		} else {
+			d.Type = t
+		}
+	}
+	if d.Type == "" && d.Nullable {
+		d.Type, d.Nullable = Null, false
+	}
+	return nil
 }
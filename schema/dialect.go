@@ -0,0 +1,124 @@
+package schema
+
+import "fmt"
+
+// Dialect is the format a provider expects tool definitions in.
+type Dialect string
+
+const (
+	// OpenAI is the tools format of the OpenAI chat completions API, the
+	// one Function is marshaled in.
+	OpenAI Dialect = "openai"
+	// OpenAIResponses is the tools format of the OpenAI Responses API.
+	OpenAIResponses Dialect = "openai-responses"
+	// Anthropic is the tools format of the Anthropic messages API.
+	Anthropic Dialect = "anthropic"
+	// Gemini is the tools format of the Google Gemini API. Parameters are
+	// restricted to the OpenAPI subset it supports.
+	Gemini Dialect = "gemini"
+	// Ollama is the tools format of the Ollama chat API.
+	Ollama Dialect = "ollama"
+)
+
+// Dialects lists the supported dialects.
+var Dialects = []Dialect{OpenAI, OpenAIResponses, Anthropic, Gemini, Ollama}
+
+// Tools returns fns in the tools format of dialect d, ready to be marshaled.
+// Tools which can't be described in d are left out, and reported by
+// Unsupported.
+func Tools(d Dialect, fns []Function) (any, error) {
+	switch d {
+	case OpenAI, Ollama:
+		return fns, nil
+
+	case OpenAIResponses:
+		type tool struct {
+			Type        string     `json:"type"`
+			Name        string     `json:"name"`
+			Description string     `json:"description,omitempty"`
+			Parameters  Definition `json:"parameters"`
+			Strict      bool       `json:"strict"`
+		}
+		tools := make([]tool, len(fns))
+		for i, f := range fns {
+			tools[i] = tool{
+				Type:        "function",
+				Name:        f.Name,
+				Description: f.Description,
+				Parameters:  objectParameters(f.Parameters),
+				Strict:      f.Strict,
+			}
+		}
+		return tools, nil
+
+	case Anthropic:
+		type tool struct {
+			Name        string     `json:"name"`
+			Description string     `json:"description,omitempty"`
+			InputSchema Definition `json:"input_schema"`
+		}
+		tools := make([]tool, len(fns))
+		for i, f := range fns {
+			tools[i] = tool{
+				Name:        f.Name,
+				Description: f.Description,
+				InputSchema: objectParameters(f.Parameters),
+			}
+		}
+		return tools, nil
+
+	case Gemini:
+		decls, _ := geminiDeclarations(fns)
+		return []map[string]any{{"functionDeclarations": decls}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown schema dialect %q", d)
+}
+
+// Unsupported returns the tools of fns which can't be described in dialect
+// d, with the reason.
+func Unsupported(d Dialect, fns []Function) map[string]error {
+	if d != Gemini {
+		return nil
+	}
+	_, errs := geminiDeclarations(fns)
+	return errs
+}
+
+type geminiDeclaration struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Parameters  *GeminiSchema `json:"parameters,omitempty"`
+}
+
+// geminiDeclarations converts fns, skipping the ones whose parameters
+// can't be converted, e.g. recursive ones.
+func geminiDeclarations(fns []Function) ([]geminiDeclaration, map[string]error) {
+	var errs map[string]error
+	decls := make([]geminiDeclaration, 0, len(fns))
+	for _, f := range fns {
+		decl := geminiDeclaration{Name: f.Name, Description: f.Description}
+		if len(f.Parameters.Properties) > 0 {
+			params, err := geminiSchema(f.Parameters, f.Parameters.Defs, nil)
+			if err != nil {
+				if errs == nil {
+					errs = make(map[string]error)
+				}
+				errs[f.Name] = err
+				continue
+			}
+			decl.Parameters = params
+		}
+		decls = append(decls, decl)
+	}
+	return decls, errs
+}
+
+// objectParameters returns params, as an empty object schema if the tool
+// takes no parameters.
+func objectParameters(params Definition) Definition {
+	if params.Type == "" {
+		params.Type = Object
+	}
+	return params
+}
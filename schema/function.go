@@ -6,6 +6,8 @@ type Function struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Parameters  Definition `json:"parameters,omitempty"`
+	// Strict is set if Parameters were made Strict.
+	Strict bool `json:"strict,omitempty"`
 }
 
 func (f Function) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// GeminiSchema is the OpenAPI subset Gemini accepts for function
+// parameters. It has no references, so definitions are inlined, and no
+// exclusive bounds, multipleOf, uniqueItems or additionalProperties.
+// Values of any type and maps, which have no properties, are described as
+// strings, maps holding a JSON object.
+type GeminiSchema struct {
+	Type             string                   `json:"type,omitempty"`
+	Format           string                   `json:"format,omitempty"`
+	Description      string                   `json:"description,omitempty"`
+	Nullable         bool                     `json:"nullable,omitempty"`
+	Enum             []string                 `json:"enum,omitempty"`
+	Properties       map[string]*GeminiSchema `json:"properties,omitempty"`
+	PropertyOrdering []string                 `json:"propertyOrdering,omitempty"`
+	Required         []string                 `json:"required,omitempty"`
+	Items            *GeminiSchema            `json:"items,omitempty"`
+	AnyOf            []*GeminiSchema          `json:"anyOf,omitempty"`
+	Minimum          *float64                 `json:"minimum,omitempty"`
+	Maximum          *float64                 `json:"maximum,omitempty"`
+	MinLength        *int                     `json:"minLength,omitempty"`
+	MaxLength        *int                     `json:"maxLength,omitempty"`
+	Pattern          string                   `json:"pattern,omitempty"`
+	MinItems         *int                     `json:"minItems,omitempty"`
+	MaxItems         *int                     `json:"maxItems,omitempty"`
+}
+
+// geminiFormats are the formats Gemini supports, by type.
+var geminiFormats = map[Type][]string{
+	String:  {"date-time"},
+	Number:  {"float", "double"},
+	Integer: {"int32", "int64"},
+}
+
+// geminiSchema converts d, resolving references to defs. refs are the
+// references being resolved, recursive schemas can't be inlined.
+func geminiSchema(d Definition, defs map[string]Definition, refs []string) (*GeminiSchema, error) {
+	if d.Ref != "" {
+		if slices.Contains(refs, d.Ref) {
+			return nil, fmt.Errorf("recursive schema %s", d.Ref)
+		}
+		def, ok := defs[strings.TrimPrefix(d.Ref, "#/$defs/")]
+		if !ok {
+			return nil, fmt.Errorf("unknown reference %s", d.Ref)
+		}
+		if d.Description != "" {
+			def.Description = d.Description
+		}
+		return geminiSchema(def, defs, append(refs, d.Ref))
+	}
+
+	alts := d.AnyOf
+	if alts == nil {
+		// the alternatives are told apart by a discriminator, so matching
+		// any of them is enough
+		alts = d.OneOf
+	}
+	if len(alts) == 2 && (alts[0].Type == Null || alts[1].Type == Null) {
+		// nullable anyOf
+		alt := alts[0]
+		if alt.Type == Null {
+			alt = alts[1]
+		}
+		if d.Description != "" {
+			alt.Description = d.Description
+		}
+		gs, err := geminiSchema(alt, defs, refs)
+		if err != nil {
+			return nil, err
+		}
+		gs.Nullable = true
+		return gs, nil
+	}
+
+	switch {
+	case d.Type == "" && alts == nil:
+		// every schema needs a type
+		d.Type = String
+		d.Description = strings.TrimSpace(d.Description + " Any value, as a string.")
+	case d.Type == Object && len(d.Properties) == 0:
+		d.Type = String
+		d.Description = strings.TrimSpace(d.Description + " A JSON object.")
+	}
+
+	gs := &GeminiSchema{
+		Type:        strings.ToUpper(string(d.Type)),
+		Description: d.Description,
+		Nullable:    d.Nullable,
+		Required:    d.Required,
+		Minimum:     d.Minimum,
+		Maximum:     d.Maximum,
+		MinLength:   d.MinLength,
+		MaxLength:   d.MaxLength,
+		Pattern:     d.Pattern,
+		MinItems:    d.MinItems,
+		MaxItems:    d.MaxItems,
+	}
+	if slices.Contains(geminiFormats[d.Type], d.Format) {
+		gs.Format = d.Format
+	}
+
+	enum := d.Enum
+	if d.Const != nil {
+		enum = []any{d.Const}
+	}
+	if enum != nil {
+		// null is allowed through Nullable
+		var vals []string
+		for _, v := range enum {
+			if v != nil {
+				vals = append(vals, fmt.Sprint(v))
+			}
+		}
+		if d.Type == String {
+			gs.Format = "enum"
+			gs.Enum = vals
+		} else {
+			// only strings can be enumerated
+			gs.Description = strings.TrimSpace(gs.Description + " One of: " + strings.Join(vals, ", ") + ".")
+		}
+	}
+
+	bare := true
+	for _, alt := range alts {
+		if alt.Type == "" && alt.Ref == "" && alt.AnyOf == nil && alt.OneOf == nil {
+			// e.g. a format for one of the values of the type
+			alt.Type = d.Type
+		}
+		ga, err := geminiSchema(alt, defs, refs)
+		if err != nil {
+			return nil, err
+		}
+		gs.AnyOf = append(gs.AnyOf, ga)
+		bare = bare && reflect.DeepEqual(*ga, GeminiSchema{Type: gs.Type})
+	}
+	if bare && gs.Type != "" {
+		// the alternatives only had constraints Gemini doesn't support
+		gs.AnyOf = nil
+	}
+
+	if d.Items != nil {
+		items, err := geminiSchema(*d.Items, defs, refs)
+		if err != nil {
+			return nil, err
+		}
+		gs.Items = items
+	}
+
+	if len(d.Properties) > 0 {
+		gs.Properties = make(map[string]*GeminiSchema, len(d.Properties))
+		for _, p := range d.Properties {
+			if _, ok := gs.Properties[p.Name]; ok {
+				continue
+			}
+			gp, err := geminiSchema(p.Definition, defs, refs)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p.Name, err)
+			}
+			gs.Properties[p.Name] = gp
+			gs.PropertyOrdering = append(gs.PropertyOrdering, p.Name)
+		}
+	}
+
+	return gs, nil
+}
@@ -36,3 +36,25 @@ type Property struct {
 	Name string `json:"-"`
 	Definition
 }
+
+func (p *Properties) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	*p = (*p)[:0]
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		prop := Property{Name: tok.(string)}
+		if err := dec.Decode(&prop.Definition); err != nil {
+			return err
+		}
+		*p = append(*p, prop)
+	}
+	return nil
+}
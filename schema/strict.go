@@ -0,0 +1,212 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+)
+
+// strictFormats are the string formats OpenAI strict mode supports.
+var strictFormats = []string{
+	"date-time", "time", "date", "duration", "email", "hostname", "ipv4", "ipv6", "uuid",
+}
+
+// Strict rewrites d, the parameters of a tool, for OpenAI strict mode:
+// every property is required, the optional ones becoming nullable, and
+// objects don't accept additional properties. Keywords strict mode ignores
+// are dropped. It returns an error if d can't be expressed in strict mode.
+func Strict(d Definition) (Definition, error) {
+	if d.Type == "" && len(d.Properties) == 0 {
+		// no parameters
+		return Definition{Type: Object, AdditionalProperties: AllowAdditional(false)}, nil
+	}
+	if d.Type != Object {
+		return Definition{}, fmt.Errorf("parameters must be an object, got %s", d.Type)
+	}
+	return strict(d, "")
+}
+
+func strict(d Definition, path string) (Definition, error) {
+	if d.Defs != nil {
+		defs := make(map[string]Definition, len(d.Defs))
+		for name, def := range d.Defs {
+			sd, err := strict(def, "#/$defs/"+name)
+			if err != nil {
+				return Definition{}, err
+			}
+			defs[name] = sd
+		}
+		d.Defs = defs
+	}
+
+	if d.OneOf != nil {
+		// strict mode only supports anyOf, the alternatives generated are
+		// told apart by a discriminator anyway
+		d.AnyOf, d.OneOf = append(d.AnyOf, d.OneOf...), nil
+	}
+	if d.AnyOf != nil {
+		alts := make([]Definition, len(d.AnyOf))
+		for i, alt := range d.AnyOf {
+			var err error
+			if alts[i], err = strict(alt, path); err != nil {
+				return Definition{}, err
+			}
+		}
+		d.AnyOf = alts
+	}
+
+	if d.Type == "" && d.Ref == "" && d.AnyOf == nil && d.Enum == nil && d.Const == nil {
+		return Definition{}, fmt.Errorf("%s: values of any type are not supported", pathOrRoot(path))
+	}
+
+	if d.Type == Object {
+		if d.PatternProperties != nil || (d.AdditionalProperties != nil && d.AdditionalProperties.Allowed) {
+			return Definition{}, fmt.Errorf("%s: objects with arbitrary properties are not supported", pathOrRoot(path))
+		}
+
+		props := make(Properties, len(d.Properties))
+		required := make([]string, len(d.Properties))
+		for i, p := range d.Properties {
+			sd, err := strict(p.Definition, path+"/"+p.Name)
+			if err != nil {
+				return Definition{}, err
+			}
+			if !slices.Contains(d.Required, p.Name) {
+				sd = nullable(sd)
+			}
+			props[i] = Property{Name: p.Name, Definition: sd}
+			required[i] = p.Name
+		}
+		d.Properties = props
+		d.Required = required
+		d.AdditionalProperties = AllowAdditional(false)
+	}
+
+	if d.Items != nil {
+		items, err := strict(*d.Items, path+"/items")
+		if err != nil {
+			return Definition{}, err
+		}
+		d.Items = &items
+	}
+
+	d.MinLength, d.MaxLength = nil, nil
+	d.UniqueItems = false
+	d.ContentEncoding = ""
+	if !slices.Contains(strictFormats, d.Format) {
+		d.Format = ""
+	}
+	return d, nil
+}
+
+// nullable returns d also accepting null.
+func nullable(d Definition) Definition {
+	if d.Nullable || d.Type == Null || slices.ContainsFunc(d.AnyOf, isNull) {
+		return d
+	}
+
+	if d.Type != "" && d.Ref == "" && d.AnyOf == nil && d.Const == nil {
+		d.Nullable = true
+		if d.Enum != nil {
+			d.Enum = append(slices.Clip(d.Enum), nil)
+		}
+		return d
+	}
+
+	desc := d.Description
+	d.Description = ""
+	return Definition{Description: desc, AnyOf: []Definition{d, {Type: Null}}}
+}
+
+func isNull(d Definition) bool { return d.Type == Null }
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "parameters"
+	}
+	return path
+}
+
+// OmitNulls returns v without the null properties d neither requires nor
+// allows to be null. Models send them for the optional properties of
+// schemas made Strict.
+func (d *Definition) OmitNulls(v any) any {
+	return validator{root: d}.omitNulls(d, v)
+}
+
+func (vd validator) omitNulls(d *Definition, v any) any {
+	if d.Ref != "" {
+		ref, ok := vd.resolve(d.Ref)
+		if !ok {
+			return v
+		}
+		d = ref
+	}
+
+	for _, alts := range [][]Definition{d.OneOf, d.AnyOf} {
+		for i := range alts {
+			if matchesConsts(&alts[i], v) {
+				return vd.omitNulls(&alts[i], v)
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if d.Properties == nil {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			i := slices.IndexFunc(d.Properties, func(p Property) bool { return p.Name == key })
+			if i < 0 {
+				out[key] = val
+				continue
+			}
+			prop := &d.Properties[i].Definition
+			if val == nil && !slices.Contains(d.Required, key) && !vd.allowsNull(prop) {
+				continue
+			}
+			out[key] = vd.omitNulls(prop, val)
+		}
+		return out
+
+	case []any:
+		if d.Items == nil {
+			return v
+		}
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = vd.omitNulls(d.Items, val)
+		}
+		return out
+	}
+	return v
+}
+
+func (vd validator) allowsNull(d *Definition) bool {
+	if d.Ref != "" {
+		ref, ok := vd.resolve(d.Ref)
+		return ok && vd.allowsNull(ref)
+	}
+	return d.Nullable || d.Type == Null || slices.ContainsFunc(d.AnyOf, isNull) || slices.ContainsFunc(d.OneOf, isNull)
+}
+
+// matchesConsts reports whether the properties of v match the const
+// properties of d, e.g. the discriminator of a union.
+func matchesConsts(d *Definition, v any) bool {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return false
+	}
+	found := false
+	for _, p := range d.Properties {
+		if p.Const == nil {
+			continue
+		}
+		if !oneOf(m[p.Name], []any{p.Const}) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
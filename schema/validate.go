@@ -0,0 +1,543 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation is a value not matching its schema.
+type Violation struct {
+	// Path is the JSON pointer of the value.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError lists all the violations found when validating a value.
+type ValidationError []Violation
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks v, a value as decoded by encoding/json, against d. It
+// returns a ValidationError listing every violation, or nil.
+func (d *Definition) Validate(v any) error {
+	return validator{root: d}.run(v)
+}
+
+// ValidateLenient is like Validate, but also accepts numbers and booleans
+// encoded as strings, numbers as booleans, and numbers and booleans as
+// strings. Objects and arrays encoded as strings must be decoded first,
+// with DecodeStrings.
+func (d *Definition) ValidateLenient(v any) error {
+	return validator{root: d, lenient: true}.run(v)
+}
+
+type validator struct {
+	// root is the schema references are resolved in.
+	root    *Definition
+	lenient bool
+}
+
+func (vd validator) run(v any) error {
+	var errs ValidationError
+	vd.validate(vd.root, "", v, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (vd validator) validate(d *Definition, path string, v any, errs *ValidationError) {
+	addf := func(format string, args ...any) {
+		*errs = append(*errs, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if d.Ref != "" {
+		ref, ok := vd.resolve(d.Ref)
+		if !ok {
+			addf("unresolved reference %s", d.Ref)
+			return
+		}
+		vd.validate(ref, path, v, errs)
+	}
+
+	if v == nil && d.Nullable {
+		return
+	}
+	if d.Type != "" && !isType(v, d.Type) {
+		if !vd.lenient || !isLenientType(v, d.Type) {
+			addf("expected %s, got %s", d.Type, typeOf(v))
+			return
+		}
+		v = coerce(v, d.Type)
+	}
+
+	if len(d.Enum) > 0 && !oneOf(v, d.Enum) {
+		addf("must be one of %s", listValues(d.Enum))
+	}
+	if d.Const != nil && !oneOf(v, []any{d.Const}) {
+		addf("must be %s", listValues([]any{d.Const}))
+	}
+	if len(d.OneOf) > 0 {
+		vd.validateAlternatives(d, path, v, d.OneOf, true, addf, errs)
+	}
+	if len(d.AnyOf) > 0 {
+		vd.validateAlternatives(d, path, v, d.AnyOf, false, addf, errs)
+	}
+
+	if n, ok := toFloat(v); ok {
+		if d.Minimum != nil && n < *d.Minimum {
+			addf("must be >= %v", *d.Minimum)
+		}
+		if d.Maximum != nil && n > *d.Maximum {
+			addf("must be <= %v", *d.Maximum)
+		}
+		if d.ExclusiveMinimum != nil && n <= *d.ExclusiveMinimum {
+			addf("must be > %v", *d.ExclusiveMinimum)
+		}
+		if d.ExclusiveMaximum != nil && n >= *d.ExclusiveMaximum {
+			addf("must be < %v", *d.ExclusiveMaximum)
+		}
+		if d.MultipleOf != nil && *d.MultipleOf != 0 {
+			if !isMultiple(n, *d.MultipleOf) {
+				addf("must be a multiple of %v", *d.MultipleOf)
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if d.MinLength != nil && n < *d.MinLength {
+			addf("must be at least %d characters long", *d.MinLength)
+		}
+		if d.MaxLength != nil && n > *d.MaxLength {
+			addf("must be at most %d characters long", *d.MaxLength)
+		}
+		if d.Pattern != "" {
+			re, err := compile(d.Pattern)
+			if err != nil {
+				addf("invalid pattern %q: %s", d.Pattern, err)
+			} else if !re.MatchString(v) {
+				addf("must match %s", d.Pattern)
+			}
+		}
+		if d.Format != "" && !checkFormat(d.Format, v) {
+			addf("must be a valid %s", d.Format)
+		}
+
+	case []any:
+		vd.validateArray(d, path, v, addf, errs)
+
+	case map[string]any:
+		vd.validateObject(d, path, v, addf, errs)
+	}
+}
+
+// validateAlternatives checks v against oneOf or anyOf schemas. If none
+// match, the violations of the closest one are reported.
+func (vd validator) validateAlternatives(d *Definition, path string, v any, alts []Definition, exactlyOne bool, addf func(string, ...any), errs *ValidationError) {
+	var closest ValidationError
+	matches := 0
+	for i := range alts {
+		var aerrs ValidationError
+		vd.validate(&alts[i], path, v, &aerrs)
+		if len(aerrs) == 0 {
+			matches++
+		} else if closest == nil || len(aerrs) < len(closest) {
+			closest = aerrs
+		}
+	}
+
+	switch {
+	case matches == 0:
+		addf("must match one of %d alternatives", len(alts))
+		*errs = append(*errs, closest...)
+	case matches > 1 && exactlyOne:
+		addf("must match exactly one alternative, matches %d", matches)
+	}
+}
+
+func (vd validator) validateArray(d *Definition, path string, v []any, addf func(string, ...any), errs *ValidationError) {
+	if d.MinItems != nil && len(v) < *d.MinItems {
+		addf("must have at least %d items", *d.MinItems)
+	}
+	if d.MaxItems != nil && len(v) > *d.MaxItems {
+		addf("must have at most %d items", *d.MaxItems)
+	}
+	if d.UniqueItems {
+		seen := make(map[string]int, len(v))
+		for i, e := range v {
+			b, _ := json.Marshal(e)
+			if j, ok := seen[string(b)]; ok {
+				addf("items %d and %d are equal", j, i)
+				break
+			}
+			seen[string(b)] = i
+		}
+	}
+	if d.Items != nil {
+		for i, e := range v {
+			vd.validate(d.Items, path+"/"+strconv.Itoa(i), e, errs)
+		}
+	}
+}
+
+func (vd validator) validateObject(d *Definition, path string, v map[string]any, addf func(string, ...any), errs *ValidationError) {
+	for _, name := range d.Required {
+		if _, ok := v[name]; !ok {
+			*errs = append(*errs, Violation{
+				Path:    path + "/" + escapePointer(name),
+				Message: "missing required property",
+			})
+		}
+	}
+
+	// iterate in schema order so violations are reported deterministically
+	known := make(map[string]bool, len(d.Properties))
+	for _, p := range d.Properties {
+		known[p.Name] = true
+		if e, ok := v[p.Name]; ok {
+			vd.validate(&p.Definition, path+"/"+escapePointer(p.Name), e, errs)
+		}
+	}
+
+	var extra []string
+	for name := range v {
+		if !known[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+
+	for _, name := range extra {
+		p := path + "/" + escapePointer(name)
+		matched := false
+		for _, pp := range d.PatternProperties {
+			re, err := compile(pp.Name)
+			if err != nil || !re.MatchString(name) {
+				continue
+			}
+			matched = true
+			vd.validate(&pp.Definition, p, v[name], errs)
+		}
+		if matched || d.AdditionalProperties == nil {
+			continue
+		}
+
+		ap := d.AdditionalProperties
+		switch {
+		case ap.Schema != nil:
+			vd.validate(ap.Schema, p, v[name], errs)
+		case !ap.Allowed:
+			*errs = append(*errs, Violation{Path: p, Message: "unexpected property"})
+		}
+	}
+}
+
+// oneOf reports whether v is equal to any of the values, as JSON.
+func oneOf(v any, vals []any) bool {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	for _, e := range vals {
+		if eb, err := json.Marshal(e); err == nil && string(eb) == string(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func listValues(vals []any) string {
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		b, _ := json.Marshal(v)
+		strs[i] = string(b)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// resolve returns the schema referenced by ref. Only references to $defs
+// of the root schema are supported.
+func (vd validator) resolve(ref string) (*Definition, bool) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, false
+	}
+	def, ok := vd.root.Defs[unescapePointer(name)]
+	return &def, ok
+}
+
+func isType(v any, t Type) bool {
+	switch t {
+	case Object:
+		_, ok := v.(map[string]any)
+		return ok
+	case Array:
+		_, ok := v.([]any)
+		return ok
+	case String:
+		_, ok := v.(string)
+		return ok
+	case Boolean:
+		_, ok := v.(bool)
+		return ok
+	case Null:
+		return v == nil
+	case Number:
+		_, ok := toFloat(v)
+		return ok
+	case Integer:
+		n, ok := toFloat(v)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	}
+	return true
+}
+
+// isLenientType reports whether v is accepted as t by lenient validation.
+func isLenientType(v any, t Type) bool {
+	s, isStr := v.(string)
+	_, isNum := toFloat(v)
+	switch t {
+	case Number, Integer:
+		n, ok := toFloat(v)
+		if isStr {
+			var err error
+			n, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			ok = err == nil
+		}
+		return ok && (t == Number || n == math.Trunc(n) && !math.IsInf(n, 0))
+	case Boolean:
+		if isStr {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		}
+		return isNum
+	case String:
+		_, isBool := v.(bool)
+		return isNum || isBool
+	}
+	return false
+}
+
+// coerce converts v, accepted by isLenientType, to t so the rest of the
+// schema can be checked.
+func coerce(v any, t Type) any {
+	switch t {
+	case Number, Integer:
+		if s, ok := v.(string); ok {
+			v, _ = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		}
+		n, _ := toFloat(v)
+		return n
+	case Boolean:
+		if s, ok := v.(string); ok {
+			b, _ := strconv.ParseBool(s)
+			return b
+		}
+		n, _ := toFloat(v)
+		return n != 0
+	case String:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b)
+		}
+		n, _ := toFloat(v)
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return v
+}
+
+// DecodeStrings returns v with the strings given for objects and arrays
+// decoded from JSON. Models send them when unsure of the type, and for the
+// maps Gemini schemas can't describe.
+func (d *Definition) DecodeStrings(v any) any {
+	return validator{root: d}.decodeStrings(d, v)
+}
+
+func (vd validator) decodeStrings(d *Definition, v any) any {
+	if d.Ref != "" {
+		ref, ok := vd.resolve(d.Ref)
+		if !ok {
+			return v
+		}
+		d = ref
+	}
+
+	for _, alts := range [][]Definition{d.OneOf, d.AnyOf} {
+		for i := range alts {
+			if matchesConsts(&alts[i], v) {
+				return vd.decodeStrings(&alts[i], v)
+			}
+		}
+		// nullable anyOf
+		if len(alts) == 2 && v != nil && (alts[0].Type == Null || alts[1].Type == Null) {
+			alt := &alts[0]
+			if alt.Type == Null {
+				alt = &alts[1]
+			}
+			return vd.decodeStrings(alt, v)
+		}
+	}
+
+	if s, ok := v.(string); ok && (d.Type == Object || d.Type == Array) {
+		var decoded any
+		if json.Unmarshal([]byte(s), &decoded) == nil && isType(decoded, d.Type) {
+			v = decoded
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			i := slices.IndexFunc(d.Properties, func(p Property) bool { return p.Name == key })
+			switch {
+			case i >= 0:
+				val = vd.decodeStrings(&d.Properties[i].Definition, val)
+			case d.AdditionalProperties != nil && d.AdditionalProperties.Schema != nil:
+				val = vd.decodeStrings(d.AdditionalProperties.Schema, val)
+			}
+			out[key] = val
+		}
+		return out
+
+	case []any:
+		if d.Items == nil {
+			return v
+		}
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = vd.decodeStrings(d.Items, val)
+		}
+		return out
+	}
+	return v
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toFloat(v any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkFormat reports whether s is valid for the format. Unknown formats are
+// only annotations and always valid.
+func checkFormat(format, s string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse(time.DateOnly, s)
+	case "time":
+		_, err = time.Parse("15:04:05Z07:00", s)
+	case "email":
+		_, err = mail.ParseAddress(s)
+	case "uri":
+		var u *url.URL
+		if u, err = url.Parse(s); err == nil && u.Scheme == "" {
+			return false
+		}
+	case "ipv4":
+		var a netip.Addr
+		a, err = netip.ParseAddr(s)
+		return err == nil && a.Is4()
+	case "ipv6":
+		var a netip.Addr
+		a, err = netip.ParseAddr(s)
+		return err == nil && a.Is6()
+	case "uuid":
+		return uuidRegex.MatchString(s)
+	}
+	return err == nil
+}
+
+var patterns sync.Map
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func unescapePointer(s string) string {
+	s = strings.ReplaceAll(s, "~1", "/")
+	return strings.ReplaceAll(s, "~0", "~")
+}
+
+func escapePointer(s string) string {
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
+
+// isMultiple reports whether n is a multiple of m, allowing for the rounding
+// errors of decimal fractions, e.g. 0.07 is a multiple of 0.01.
+func isMultiple(n, m float64) bool {
+	q := n / m
+	return math.Abs(q-math.Round(q)) <= 1e-9*math.Max(1, math.Abs(q))
+}
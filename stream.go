@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// eventStream writes tool progress to the client as it happens, either as
+// Server-Sent Events or as newline delimited JSON.
+type eventStream struct {
+	mu  sync.Mutex
+	w   http.ResponseWriter
+	sse bool
+}
+
+// newEventStream returns a stream if the client asked for one.
+func newEventStream(w http.ResponseWriter, r *http.Request) *eventStream {
+	if _, ok := w.(http.Flusher); !ok {
+		return nil
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/event-stream"):
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		return &eventStream{w: w, sse: true}
+	case strings.Contains(accept, "application/x-ndjson"):
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		return &eventStream{w: w}
+	}
+	return nil
+}
+
+func (s *eventStream) Progress(msg string) error {
+	return s.send("progress", map[string]string{"message": msg})
+}
+
+func (s *eventStream) Partial(v any) error {
+	return s.send("partial", v)
+}
+
+func (s *eventStream) send(event string, data any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.sse {
+		var b []byte
+		if b, err = json.Marshal(data); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b)
+	} else {
+		err = json.NewEncoder(s.w).Encode(map[string]any{
+			"event": event,
+			"data":  data,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	s.w.(http.Flusher).Flush()
+	return nil
+}
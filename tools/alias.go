@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FoldNames matches argument names ignoring case, underscores and dashes,
+// so domainName or DomainName are accepted for domain_name. The schema
+// still only lists the canonical names.
+func FoldNames() Option {
+	return func(c *config) { c.foldNames = true }
+}
+
+// normalizer renames the properties of decoded arguments to the names used
+// in the schema, before they are validated and converted.
+type normalizer func(any) any
+
+// objectNormalizer renames the properties of an object.
+type objectNormalizer struct {
+	// props maps the canonical names to the normalizer of their value
+	props map[string]normalizer
+	// names maps aliases, and folded names if enabled, to canonical names
+	names map[string]string
+	fold  bool
+}
+
+func newObjectNormalizer(fold bool) *objectNormalizer {
+	return &objectNormalizer{
+		props: map[string]normalizer{},
+		names: map[string]string{},
+		fold:  fold,
+	}
+}
+
+func (o *objectNormalizer) add(name string, aliases []string, n normalizer) {
+	o.props[name] = n
+	for _, alias := range aliases {
+		o.names[alias] = name
+	}
+	if o.fold {
+		if _, ok := o.names[foldName(name)]; !ok {
+			o.names[foldName(name)] = name
+		}
+	}
+}
+
+func (o *objectNormalizer) canonical(key string) (string, bool) {
+	if name, ok := o.names[key]; ok {
+		return name, true
+	}
+	if o.fold {
+		name, ok := o.names[foldName(key)]
+		return name, ok
+	}
+	return "", false
+}
+
+func (o *objectNormalizer) normalize(val any) any {
+	m, ok := val.(map[string]any)
+	if !ok {
+		return val
+	}
+
+	out := make(map[string]any, len(m))
+	var rest []string
+	for _, key := range sortedKeys(m) {
+		n, ok := o.props[key]
+		if !ok {
+			rest = append(rest, key)
+			continue
+		}
+		out[key] = normalize(n, m[key])
+	}
+	for _, key := range rest {
+		name, ok := o.canonical(key)
+		if _, taken := out[name]; !ok || taken {
+			// left for validation to report
+			out[key] = m[key]
+			continue
+		}
+		out[name] = normalize(o.props[name], m[key])
+	}
+	return out
+}
+
+func normalize(n normalizer, val any) any {
+	if n == nil {
+		return val
+	}
+	return n(val)
+}
+
+// foldName returns name lower cased, without underscores and dashes.
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// fieldAliases returns the aliases set in the llm tag of f, e.g.
+// `llm:"domain,alias=host|hostname"`.
+func fieldAliases(f reflect.StructField) []string {
+	tag, ok := f.Tag.Lookup("llm")
+	if !ok {
+		return nil
+	}
+	for _, opt := range strings.Split(tag, ",")[1:] {
+		if list, ok := strings.CutPrefix(strings.TrimSpace(opt), "alias="); ok {
+			return strings.Split(list, "|")
+		}
+	}
+	return nil
+}
+
+// normalizer returns the normalizer of values of type t, nil if none of
+// their properties can be renamed.
+func (c *config) normalizer(t reflect.Type) normalizer {
+	if !c.renames(t, map[reflect.Type]bool{}) {
+		return nil
+	}
+	return c.buildNormalizer(t, map[reflect.Type]*normalizer{})
+}
+
+// renames reports whether any property of values of type t can be renamed.
+func (c *config) renames(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] || c.opaque(t) {
+		return false
+	}
+	seen[t] = true
+
+	if u, ok := c.unions[t]; ok {
+		for _, vt := range u.types {
+			if c.renames(vt, seen) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return c.renames(t.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			if c.foldNames || fieldAliases(f.StructField) != nil || c.renames(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// opaque reports whether values of type t are converted as a whole, without
+// looking at their properties.
+func (c *config) opaque(t reflect.Type) bool {
+	if _, ok := c.mapping(t); ok {
+		return true
+	}
+	return unmarshalerConverter(t) != nil || c.enum(t) != nil
+}
+
+func (c *config) buildNormalizer(t reflect.Type, building map[reflect.Type]*normalizer) normalizer {
+	if n, ok := building[t]; ok {
+		// recursive type, n is set once t is built
+		return func(val any) any { return normalize(*n, val) }
+	}
+	if c.opaque(t) {
+		return nil
+	}
+
+	if u, ok := c.unions[t]; ok {
+		variants := make(map[string]normalizer, len(u.names))
+		for i, name := range u.names {
+			variants[name] = c.buildNormalizer(u.types[i], building)
+		}
+		return func(val any) any {
+			m, ok := val.(map[string]any)
+			if !ok {
+				return val
+			}
+			name, _ := m[u.discriminator].(string)
+			n := variants[name]
+			if n == nil {
+				return val
+			}
+			// the discriminator may not be a field of the variant
+			disc, ok := m[u.discriminator]
+			out := n(val).(map[string]any)
+			if ok {
+				out[u.discriminator] = disc
+			}
+			return out
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return c.buildNormalizer(t.Elem(), building)
+
+	case reflect.Slice, reflect.Array:
+		elem := c.buildNormalizer(t.Elem(), building)
+		if elem == nil {
+			return nil
+		}
+		return func(val any) any {
+			vals, ok := val.([]any)
+			if !ok {
+				return val
+			}
+			out := make([]any, len(vals))
+			for i, v := range vals {
+				out[i] = elem(v)
+			}
+			return out
+		}
+
+	case reflect.Map:
+		elem := c.buildNormalizer(t.Elem(), building)
+		if elem == nil {
+			return nil
+		}
+		return func(val any) any {
+			m, ok := val.(map[string]any)
+			if !ok {
+				return val
+			}
+			out := make(map[string]any, len(m))
+			for k, v := range m {
+				out[k] = elem(v)
+			}
+			return out
+		}
+
+	case reflect.Struct:
+		var n normalizer
+		building[t] = &n
+		defer delete(building, t)
+
+		o := newObjectNormalizer(c.foldNames)
+		for _, f := range structFields(t) {
+			o.add(f.name, fieldAliases(f.StructField), c.buildNormalizer(f.Type, building))
+		}
+		n = o.normalize
+		return n
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// constraintKeys are the struct tags and doc directives setting schema
+// constraints. min and max apply to the value of numbers, the length of
+// strings and the number of items of arrays.
+var constraintKeys = []string{
+	"min", "max", "exclusiveMin", "exclusiveMax", "multipleOf",
+	"minLength", "maxLength", "pattern", "format",
+	"minItems", "maxItems", "unique",
+}
+
+// directiveRegex matches constraints at the end of a doc line, e.g.
+// "age of the person [min=0, max=150]". The alias directive lists other
+// names accepted for a function parameter, e.g. "[alias=host|hostname]".
+var directiveRegex = regexp.MustCompile(`\s*\[([a-zA-Z]+=[^\]=]*(?:,\s*[a-zA-Z]+=[^\]=]*)*)\]\s*$`)
+
+// tagConstraints applies the constraints set in the tags of f.
+func tagConstraints(def *schema.Definition, f reflect.StructField) {
+	for _, key := range constraintKeys {
+		val, ok := f.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setConstraint(def, key, val); err != nil {
+			panic(fmt.Sprintf("field %s: %s", f.Name, err))
+		}
+	}
+}
+
+// docConstraints applies the constraints found at the end of doc and
+// returns the rest of doc.
+func docConstraints(def *schema.Definition, doc string) string {
+	m := directiveRegex.FindStringSubmatchIndex(doc)
+	if m == nil {
+		return doc
+	}
+
+	var kvs [][2]string
+	for _, kv := range strings.Split(doc[m[2]:m[3]], ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		if key == aliasKey {
+			continue
+		}
+		if !isConstraintKey(key) {
+			// not a directive, just a doc ending in brackets
+			return doc
+		}
+		kvs = append(kvs, [2]string{key, strings.TrimSpace(val)})
+	}
+
+	for _, kv := range kvs {
+		if err := setConstraint(def, kv[0], kv[1]); err != nil {
+			panic(err.Error())
+		}
+	}
+	return doc[:m[0]]
+}
+
+const aliasKey = "alias"
+
+// docAliases returns the aliases listed by the alias directive of doc.
+func docAliases(doc string) []string {
+	m := directiveRegex.FindStringSubmatch(doc)
+	if m == nil {
+		return nil
+	}
+	for _, kv := range strings.Split(m[1], ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		if key == aliasKey {
+			return strings.Split(strings.TrimSpace(val), "|")
+		}
+	}
+	return nil
+}
+
+func isConstraintKey(key string) bool {
+	for _, k := range constraintKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setConstraint(def *schema.Definition, key, val string) error {
+	switch key {
+	case "min", "max":
+		switch def.Type {
+		case schema.String:
+			key += "Length"
+		case schema.Array:
+			key += "Items"
+		default:
+			key += "imum"
+		}
+	}
+
+	var err error
+	switch key {
+	case "minimum":
+		def.Minimum, err = parseConstraintFloat(val)
+	case "maximum":
+		def.Maximum, err = parseConstraintFloat(val)
+	case "exclusiveMin":
+		def.ExclusiveMinimum, err = parseConstraintFloat(val)
+	case "exclusiveMax":
+		def.ExclusiveMaximum, err = parseConstraintFloat(val)
+	case "multipleOf":
+		def.MultipleOf, err = parseConstraintFloat(val)
+	case "minLength":
+		def.MinLength, err = parseConstraintInt(val)
+	case "maxLength":
+		def.MaxLength, err = parseConstraintInt(val)
+	case "minItems":
+		def.MinItems, err = parseConstraintInt(val)
+	case "maxItems":
+		def.MaxItems, err = parseConstraintInt(val)
+	case "pattern":
+		if _, err = regexp.Compile(val); err == nil {
+			def.Pattern = val
+		}
+	case "format":
+		def.Format = val
+	case "unique":
+		def.UniqueItems, err = strconv.ParseBool(val)
+	default:
+		err = fmt.Errorf("unknown constraint")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s constraint %q: %w", key, val, err)
+	}
+	return nil
+}
+
+func parseConstraintFloat(s string) (*float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseConstraintInt(s string) (*int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("must not be negative")
+	}
+	return &i, nil
+}
+
+// intRange sets the bounds of fixed width integer types.
+func intRange(def *schema.Definition, t reflect.Type) {
+	var min, max float64
+	switch t.Kind() {
+	case reflect.Int8:
+		min, max = math.MinInt8, math.MaxInt8
+	case reflect.Int16:
+		min, max = math.MinInt16, math.MaxInt16
+	case reflect.Int32:
+		min, max = math.MinInt32, math.MaxInt32
+	case reflect.Uint8:
+		max = math.MaxUint8
+	case reflect.Uint16:
+		max = math.MaxUint16
+	case reflect.Uint32:
+		max = math.MaxUint32
+	case reflect.Uint, reflect.Uint64:
+		// too large to be represented exactly
+		def.Minimum = &min
+		return
+	default:
+		return
+	}
+
+	def.Minimum, def.Maximum = &min, &max
+}
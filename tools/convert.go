@@ -4,42 +4,69 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+
+	"github.com/byte-sat/llum-tools/schema"
 )
 
 type argConverter func(any) (reflect.Value, error)
 
-func converter(t reflect.Type) argConverter {
+func (c *config) converter(t reflect.Type) argConverter {
+	if tm, ok := c.mapping(t); ok {
+		return tm.conv
+	}
+	if conv := unmarshalerConverter(t); conv != nil {
+		return conv
+	}
+	if e := c.enum(t); e != nil {
+		return e.converter(t, c.kindConverter(t))
+	}
+	return c.kindConverter(t)
+}
+
+func (c *config) kindConverter(t reflect.Type) argConverter {
 	switch t.Kind() {
 	case reflect.Bool:
-		return parseBool
+		return c.parseBool(t)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return parseInt(t)
+		return c.parseInt(t)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return parseUint(t)
+		return c.parseUint(t)
 	case reflect.Float32, reflect.Float64:
-		return parseFloat(t)
+		return c.parseFloat(t)
 	case reflect.String:
-		return justConvert(t)
+		return c.parseString(t)
 	case reflect.Array:
-		return convertArray(t)
+		return c.convertArray(t)
 	case reflect.Slice:
-		return convertSlice(t)
+		return c.convertSlice(t)
 	case reflect.Struct:
-		return convertStruct(t)
+		return c.convertStruct(t)
 	case reflect.Map:
-		return convertMap(t)
+		return c.convertMap(t)
 	case reflect.Ptr:
-		return convertPtr(t)
+		return c.convertPtr(t)
+	case reflect.Interface:
+		if u, ok := c.unions[t]; ok {
+			return c.unionConverter(t, u)
+		}
+		if t.NumMethod() == 0 {
+			return anyConverter(t)
+		}
+		panic(fmt.Sprintf("unsupported argument type %s", t))
 	default:
 		panic(fmt.Sprintf("unsupported argument type %s", t.Kind()))
 
 	}
 }
 
-func convertPtr(t reflect.Type) argConverter {
+func (c *config) convertPtr(t reflect.Type) argConverter {
 	it := t.Elem()
-	conv := converter(it)
+	conv := c.converter(it)
 	return func(val any) (reflect.Value, error) {
+		if val == nil {
+			return reflect.Zero(t), nil
+		}
+
 		rv, err := conv(val)
 		if err != nil {
 			return rv, err
@@ -52,12 +79,12 @@ func convertPtr(t reflect.Type) argConverter {
 	}
 }
 
-func convertArray(t reflect.Type) argConverter {
-	conv := converter(t.Elem())
+func (c *config) convertArray(t reflect.Type) argConverter {
+	conv := c.converter(t.Elem())
 	return func(val any) (reflect.Value, error) {
 		rv := reflect.ValueOf(val)
 		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+			return reflect.Value{}, typeError(t, val)
 		}
 
 		if rv, err, ok := tryConvert(t, rv); ok {
@@ -73,29 +100,34 @@ func convertArray(t reflect.Type) argConverter {
 		arr := ptr.Elem()
 
 		if rv.Len() != arr.Len() {
-			return reflect.Value{}, fmt.Errorf("array length mismatch: %d != %d", rv.Len(), arr.Len())
+			return reflect.Value{}, fmt.Errorf("expected %d items, got %d", arr.Len(), rv.Len())
 		}
 
+		var errs []schema.Violation
 		for i := 0; i < rv.Len(); i++ {
 			v := rv.Index(i)
 			v, err := conv(v.Interface())
 			if err != nil {
-				return reflect.Value{}, fmt.Errorf("%s[%d]: %w", t.String(), i, err)
+				errs = appendArgError(errs, "/"+strconv.Itoa(i), err)
+				continue
 			}
 
 			arr.Index(i).Set(v)
 		}
+		if errs != nil {
+			return reflect.Value{}, &ArgumentError{Violations: errs}
+		}
 
 		return arr, nil
 	}
 }
 
-func convertSlice(t reflect.Type) argConverter {
-	conv := converter(t.Elem())
+func (c *config) convertSlice(t reflect.Type) argConverter {
+	conv := c.converter(t.Elem())
 	return func(val any) (reflect.Value, error) {
 		rv := reflect.ValueOf(val)
 		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+			return reflect.Value{}, typeError(t, val)
 		}
 
 		if rv, err, ok := tryConvert(t, rv); ok {
@@ -112,30 +144,35 @@ func convertSlice(t reflect.Type) argConverter {
 		sl.Grow(rv.Len())
 		sl.SetLen(rv.Len())
 
+		var errs []schema.Violation
 		for i := 0; i < rv.Len(); i++ {
 			v := rv.Index(i)
 			v, err := conv(v.Interface())
 			if err != nil {
-				return reflect.Value{}, fmt.Errorf("%s[%d]: %w", t.String(), i, err)
+				errs = appendArgError(errs, "/"+strconv.Itoa(i), err)
+				continue
 			}
 
 			sl.Index(i).Set(v)
 		}
+		if errs != nil {
+			return reflect.Value{}, &ArgumentError{Violations: errs}
+		}
 
 		return sl, nil
 	}
 }
 
-func convertMap(t reflect.Type) argConverter {
+func (c *config) convertMap(t reflect.Type) argConverter {
 	if t.Key().Kind() != reflect.String {
 		panic("map keys must be strings")
 	}
 
-	conv := converter(t.Elem())
+	conv := c.converter(t.Elem())
 	return func(val any) (reflect.Value, error) {
 		rv := reflect.ValueOf(val)
 		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+			return reflect.Value{}, typeError(t, val)
 		}
 
 		if rv, err, ok := tryConvert(t, rv); ok {
@@ -147,6 +184,7 @@ func convertMap(t reflect.Type) argConverter {
 			}
 		}
 
+		var errs []schema.Violation
 		mv := reflect.MakeMapWithSize(t, rv.Len())
 		iter := rv.MapRange()
 		for iter.Next() {
@@ -155,35 +193,43 @@ func convertMap(t reflect.Type) argConverter {
 
 			v, err := conv(v.Interface())
 			if err != nil {
-				return reflect.Value{}, fmt.Errorf("%s[%s]: %w", t.String(), k.Interface(), err)
+				errs = appendArgError(errs, pointerPath(k.String()), err)
+				continue
 			}
 
-			mv.SetMapIndex(k, v)
+			mv.SetMapIndex(k.Convert(t.Key()), v)
+		}
+		if errs != nil {
+			return reflect.Value{}, &ArgumentError{Violations: errs}
 		}
 
 		return mv, nil
 	}
 }
 
-func convertStruct(t reflect.Type) argConverter {
-	nameidx := map[string]int{}
-	converters := make([]argConverter, t.NumField())
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		if !f.IsExported() {
-			continue
-		}
+func (c *config) convertStruct(t reflect.Type) argConverter {
+	if conv, ok := c.building[t]; ok {
+		// recursive type, conv is set once t is built
+		return func(val any) (reflect.Value, error) { return (*conv)(val) }
+	}
 
-		name := fieldName(f)
-		if name == "" {
-			continue
-		}
+	if c.building == nil {
+		c.building = make(map[reflect.Type]*argConverter)
+		defer func() { c.building = nil }()
+	}
+	var conv argConverter
+	c.building[t] = &conv
+	defer delete(c.building, t)
 
-		nameidx[name] = i
-		converters[i] = converter(f.Type)
+	fields := structFields(t)
+	nameidx := map[string]int{}
+	converters := make([]argConverter, len(fields))
+	for i, f := range fields {
+		nameidx[f.name] = i
+		converters[i] = c.converter(f.Type)
 	}
 
-	return func(val any) (reflect.Value, error) {
+	conv = func(val any) (reflect.Value, error) {
 		if rv, err, ok := tryConvert(t, reflect.ValueOf(val)); ok {
 			if err != nil {
 				return reflect.Value{}, err
@@ -195,156 +241,131 @@ func convertStruct(t reflect.Type) argConverter {
 
 		m, ok := val.(map[string]any)
 		if !ok {
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+			return reflect.Value{}, typeError(t, val)
 		}
 
+		var errs []schema.Violation
 		ptr := reflect.New(t)
 		rv := ptr.Elem()
-		for key, val := range m {
+		for _, key := range sortedKeys(m) {
 			idx, ok := nameidx[key]
 			if !ok {
-				return reflect.Value{}, fmt.Errorf("unexpected field: %s", key)
+				errs = append(errs, schema.Violation{Path: pointerPath(key), Message: "unexpected property"})
+				continue
 			}
 
-			v, err := converters[idx](val)
+			v, err := converters[idx](m[key])
 			if err != nil {
-				return reflect.Value{}, fmt.Errorf("%s.%s: %w", t.String(), t.Field(idx).Name, err)
+				errs = appendArgError(errs, pointerPath(key), err)
+				continue
 			}
 
-			rv.Field(idx).Set(v)
+			fieldByIndex(rv, fields[idx].Index).Set(v)
+		}
+		if errs != nil {
+			return reflect.Value{}, &ArgumentError{Violations: errs}
 		}
 
 		return rv, nil
 	}
+	return conv
 }
 
-func parseInt(t reflect.Type) argConverter {
+func (c *config) parseInt(t reflect.Type) argConverter {
 	return func(val any) (reflect.Value, error) {
-		if rv, err, ok := tryConvert(t, reflect.ValueOf(val)); ok {
-			if err != nil {
-				return reflect.Value{}, err
-			}
-			if ok {
-				return rv, nil
-			}
+		i, err := toInt(val, c.lenient())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s %v: %w", typeName(t), val, err)
 		}
 
-		switch v := val.(type) {
-		case string:
-			var err error
-			val, err = strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				return reflect.Value{}, err
-			}
-
-		case float32:
-			val = int(v)
-		case float64:
-			val = int(v)
-
-		default:
+		rv := reflect.New(t).Elem()
+		if rv.OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("%d out of range for %s", i, t.Kind())
+		}
+		rv.SetInt(i)
+		return rv, nil
+	}
+}
 
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+func (c *config) parseUint(t reflect.Type) argConverter {
+	return func(val any) (reflect.Value, error) {
+		u, err := toUint(val, c.lenient())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s %v: %w", typeName(t), val, err)
 		}
 
-		return reflect.ValueOf(val).Convert(t), nil
+		rv := reflect.New(t).Elem()
+		if rv.OverflowUint(u) {
+			return reflect.Value{}, fmt.Errorf("%d out of range for %s", u, t.Kind())
+		}
+		rv.SetUint(u)
+		return rv, nil
 	}
 }
 
-func parseUint(t reflect.Type) argConverter {
+func (c *config) parseFloat(t reflect.Type) argConverter {
 	return func(val any) (reflect.Value, error) {
-		if rv, err, ok := tryConvert(t, reflect.ValueOf(val)); ok {
-			if err != nil {
-				return reflect.Value{}, err
-			}
-			if ok {
-				return rv, nil
-			}
+		f, err := toFloat(val, c.lenient())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s %v: %w", typeName(t), val, err)
 		}
-		switch v := val.(type) {
-		case string:
-			var err error
-			val, err = strconv.ParseUint(v, 10, 64)
-			if err != nil {
-				return reflect.Value{}, err
-			}
 
-		default:
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+		rv := reflect.New(t).Elem()
+		if rv.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%v out of range for %s", f, t.Kind())
 		}
-
-		return reflect.ValueOf(val).Convert(t), nil
+		rv.SetFloat(f)
+		return rv, nil
 	}
 }
 
-func parseFloat(t reflect.Type) argConverter {
+func (c *config) parseBool(t reflect.Type) argConverter {
 	return func(val any) (reflect.Value, error) {
-		if rv, err, ok := tryConvert(t, reflect.ValueOf(val)); ok {
-			if err != nil {
-				return reflect.Value{}, err
-			}
-			if ok {
-				return rv, nil
-			}
+		rv := reflect.New(t).Elem()
+		if b, ok := val.(bool); ok {
+			rv.SetBool(b)
+			return rv, nil
+		}
+		if !c.lenient() {
+			return reflect.Value{}, typeError(t, val)
 		}
-		switch v := val.(type) {
-		case string:
-			var err error
-			val, err = strconv.ParseFloat(v, 64)
+
+		if s, ok := val.(string); ok {
+			b, err := strconv.ParseBool(s)
 			if err != nil {
 				return reflect.Value{}, err
 			}
-
-		default:
-			return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+			rv.SetBool(b)
+			return rv, nil
 		}
 
-		return reflect.ValueOf(val).Convert(t), nil
-	}
-}
-
-func parseBool(val any) (reflect.Value, error) {
-	switch v := val.(type) {
-	case string:
-		var err error
-		val, err = strconv.ParseBool(v)
+		f, err := toFloat(val, false)
 		if err != nil {
-			return reflect.Value{}, err
-		}
-	case int:
-		val = v != 0
-	case int8:
-		val = v != 0
-	case int16:
-		val = v != 0
-	case int32:
-		val = v != 0
-	case int64:
-		val = v != 0
-	case uint8:
-		val = v != 0
-	case uint16:
-		val = v != 0
-	case uint32:
-		val = v != 0
-	case uint64:
-		val = v != 0
-	case bool:
-		val = v
-	default:
-		return reflect.Value{}, fmt.Errorf("cannot convert %T to bool", val)
+			return reflect.Value{}, typeError(t, val)
+		}
+		rv.SetBool(f != 0)
+		return rv, nil
 	}
-
-	return reflect.ValueOf(val), nil
 }
 
-func justConvert(t reflect.Type) argConverter {
+func (c *config) parseString(t reflect.Type) argConverter {
 	return func(val any) (reflect.Value, error) {
-		if rv, err, ok := tryConvert(t, reflect.ValueOf(val)); ok {
-			return rv, err
+		rv := reflect.New(t).Elem()
+		switch v := reflect.ValueOf(val); {
+		case v.Kind() == reflect.String:
+			rv.SetString(v.String())
+		case c.lenient() && v.Kind() == reflect.Bool:
+			rv.SetString(strconv.FormatBool(v.Bool()))
+		case c.lenient():
+			f, err := toFloat(val, false)
+			if err != nil {
+				return reflect.Value{}, typeError(t, val)
+			}
+			rv.SetString(strconv.FormatFloat(f, 'f', -1, 64))
+		default:
+			return reflect.Value{}, typeError(t, val)
 		}
-
-		return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", val, t)
+		return rv, nil
 	}
 }
 
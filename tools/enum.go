@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// enumMethod is the method named types with a fixed set of values can
+// implement to list them, e.g.
+//
+//	func (Unit) Enum() []Unit { return []Unit{Celsius, Fahrenheit} }
+//
+// It is called on the zero value of the type.
+const enumMethod = "Enum"
+
+type enum struct {
+	vals []reflect.Value
+	// names of the values, if the model should use them instead of the
+	// values themselves
+	names []string
+}
+
+// Enum restricts the named type T to the given values. If T is an integer
+// type implementing fmt.Stringer, the model uses the value names instead.
+func Enum[T comparable](values ...T) Option {
+	t := reflect.TypeFor[T]()
+	vals := make([]reflect.Value, len(values))
+	for i, v := range values {
+		vals[i] = reflect.ValueOf(v)
+	}
+	e := newEnum(t, vals)
+	return func(c *config) { c.enums = withKey(c.enums, t, e) }
+}
+
+// NamedEnum restricts the named type T to the given values, which the model
+// refers to by name.
+func NamedEnum[T comparable](names map[string]T) Option {
+	t := reflect.TypeFor[T]()
+	keys := slices.SortedFunc(maps.Keys(names), func(a, b string) int {
+		return cmp.Or(compareValues(reflect.ValueOf(names[a]), reflect.ValueOf(names[b])), cmp.Compare(a, b))
+	})
+
+	e := &enum{}
+	for _, name := range keys {
+		e.vals = append(e.vals, reflect.ValueOf(names[name]))
+		e.names = append(e.names, name)
+	}
+	return func(c *config) { c.enums = withKey(c.enums, t, e) }
+}
+
+func newEnum(t reflect.Type, vals []reflect.Value) *enum {
+	e := &enum{vals: vals}
+	if !isInt(t) || !t.Implements(stringerType) {
+		return e
+	}
+
+	for _, v := range vals {
+		e.names = append(e.names, v.Interface().(fmt.Stringer).String())
+	}
+	return e
+}
+
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+// enum returns the values t is restricted to, or nil.
+func (c *config) enum(t reflect.Type) *enum {
+	if e, ok := c.enums[t]; ok {
+		return e
+	}
+
+	if t.Name() == "" {
+		return nil
+	}
+	m, ok := t.MethodByName(enumMethod)
+	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || m.Type.Out(0) != reflect.SliceOf(t) {
+		return nil
+	}
+
+	out := m.Func.Call([]reflect.Value{reflect.Zero(t)})[0]
+	vals := make([]reflect.Value, out.Len())
+	for i := range vals {
+		vals[i] = out.Index(i)
+	}
+	return newEnum(t, vals)
+}
+
+func (e *enum) definition(def schema.Definition) schema.Definition {
+	if e.names != nil {
+		def = schema.Definition{Type: schema.String}
+		for _, name := range e.names {
+			def.Enum = append(def.Enum, name)
+		}
+		return def
+	}
+
+	// the schema only sees the underlying value, not the named type
+	def.Minimum, def.Maximum = nil, nil
+	for _, v := range e.vals {
+		def.Enum = append(def.Enum, underlying(v))
+	}
+	return def
+}
+
+func (e *enum) converter(t reflect.Type, conv argConverter) argConverter {
+	if e.names != nil {
+		return func(val any) (reflect.Value, error) {
+			name, ok := val.(string)
+			if !ok {
+				return reflect.Value{}, typeError(t, val)
+			}
+			for i, n := range e.names {
+				if n == name {
+					return e.vals[i], nil
+				}
+			}
+			return reflect.Value{}, fmt.Errorf("invalid value %q", name)
+		}
+	}
+
+	return func(val any) (reflect.Value, error) {
+		rv, err := conv(val)
+		if err != nil {
+			return rv, err
+		}
+		for _, v := range e.vals {
+			if rv.Equal(v) {
+				return rv, nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("invalid value %v", val)
+	}
+}
+
+func isInt(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func underlying(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	}
+	return v.Interface()
+}
+
+func compareValues(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	}
+	return 0
+}
+
+// withKey returns a copy of m with k set to v, leaving m untouched as it
+// may be shared with the config of other tools.
+func withKey[K comparable, V any](m map[K]V, k K, v V) map[K]V {
+	m = maps.Clone(m)
+	if m == nil {
+		m = make(map[K]V)
+	}
+	m[k] = v
+	return m
+}
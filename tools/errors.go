@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+var (
+	ErrNotFound         = errors.New("tool not found")
+	ErrInvalidArguments = errors.New("invalid arguments")
+)
+
+// ArgumentError lists every problem found with the arguments of a tool
+// call. It matches ErrInvalidArguments with errors.Is.
+type ArgumentError struct {
+	// Violations point to the invalid arguments using JSON pointers, with
+	// the names used in the schema.
+	Violations []schema.Violation
+}
+
+func (e *ArgumentError) Error() string {
+	return ErrInvalidArguments.Error() + ": " + schema.ValidationError(e.Violations).Error()
+}
+
+func (e *ArgumentError) Is(target error) bool {
+	return target == ErrInvalidArguments
+}
+
+func argError(path, msg string) *ArgumentError {
+	return &ArgumentError{Violations: []schema.Violation{{Path: path, Message: msg}}}
+}
+
+// appendArgError adds err, found at path, to errs. The paths of nested
+// ArgumentErrors are prefixed with path.
+func appendArgError(errs []schema.Violation, path string, err error) []schema.Violation {
+	var aerr *ArgumentError
+	if !errors.As(err, &aerr) {
+		return append(errs, schema.Violation{Path: path, Message: err.Error()})
+	}
+
+	for _, v := range aerr.Violations {
+		errs = append(errs, schema.Violation{Path: path + v.Path, Message: v.Message})
+	}
+	return errs
+}
+
+// pointerPath returns the JSON pointer path segment for name.
+func pointerPath(name string) string {
+	name = strings.ReplaceAll(name, "~", "~0")
+	return "/" + strings.ReplaceAll(name, "/", "~1")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func typeError(t reflect.Type, val any) error {
+	return fmt.Errorf("expected %s, got %s", typeName(t), jsonType(val))
+}
+
+// typeName returns the JSON type used for t.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Array, reflect.Slice:
+		return "array"
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	}
+	return "object"
+}
+
+// jsonType returns the JSON type of val.
+func jsonType(val any) string {
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Invalid:
+		return "null"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Array, reflect.Slice:
+		return "array"
+	}
+	return "object"
+}
+
+// PanicError is returned when a tool panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("tool panicked: %v", e.Value)
+}
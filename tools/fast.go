@@ -0,0 +1,476 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// jsonInvoker is implemented by invokers which can decode their arguments
+// straight from JSON.
+type jsonInvoker interface {
+	InvokeJSON(*Injector, json.RawMessage) (any, error)
+}
+
+// argsStruct returns a struct type encoding/json can decode the arguments
+// into, with a pointer field for each argument so missing ones can be told
+// apart. It returns nil if encoding/json would decode the arguments
+// differently than the converters.
+//
+// encoding/json is more forgiving, matching property names case
+// insensitively and decoding nulls to zero values, which jsonDecoder
+// guards against. Values only accepted by the Lenient policy fail to
+// decode, and are left to the converters.
+func (c *config) argsStruct(names []string, types []reflect.Type) reflect.Type {
+	fields := make([]reflect.StructField, len(names))
+	seen := map[reflect.Type]bool{}
+	for i, t := range types {
+		if !c.decodable(t, seen) {
+			return nil
+		}
+		fields[i] = reflect.StructField{
+			Name: "A" + strconv.Itoa(i),
+			Type: reflect.PointerTo(t),
+			Tag:  reflect.StructTag(`json:"` + names[i] + `"`),
+		}
+	}
+	return reflect.StructOf(fields)
+}
+
+// decodable reports whether encoding/json decodes t the way the converter
+// returned by c.converter does.
+func (c *config) decodable(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return true
+	}
+	seen[t] = true
+
+	if _, ok := c.types[t]; ok {
+		return false
+	}
+	if _, ok := c.unions[t]; ok {
+		return false
+	}
+	if c.enum(t) != nil {
+		return false
+	}
+
+	switch t {
+	case reflect.TypeFor[time.Time](), reflect.TypeFor[json.RawMessage](), reflect.TypeFor[[]byte]():
+		return true
+	}
+	if implements(t, jsonUnmarshalerType) || implements(t, textUnmarshalerType) {
+		return true
+	}
+	if _, ok := wellKnownFor(t); ok {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+
+	case reflect.Pointer, reflect.Slice:
+		return c.decodable(t.Elem(), seen)
+
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && c.decodable(t.Elem(), seen)
+
+	case reflect.Interface:
+		return t.NumMethod() == 0
+
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			if f.inlined || f.name != jsonName(f.StructField) || hasTagOption(f.StructField, "string") || !c.decodable(f.Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// arrays are zero filled or truncated instead of rejected
+	return false
+}
+
+// InvokeJSON decodes the arguments with encoding/json instead of the
+// converters if possible, falling back to Invoke.
+func (f *codocFuncInvoker) InvokeJSON(inj *Injector, raw json.RawMessage) (any, error) {
+	if f.json == nil {
+		return f.invokeRaw(inj, raw)
+	}
+	decoded, ok, err := f.json.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.invokeRaw(inj, raw)
+	}
+
+	vals := make([]reflect.Value, 0, len(f.injected)+len(f.argNames))
+	vals, err = f.appendInjected(vals, f.injector(inj))
+	if err != nil {
+		return nil, err
+	}
+	for i := range f.argNames {
+		arg := decoded.Field(i)
+		if arg.IsNil() {
+			// missing, left to Invoke to report
+			return f.invokeRaw(inj, raw)
+		}
+		vals = append(vals, arg.Elem())
+	}
+
+	return f.call(vals)
+}
+
+func (f *codocFuncInvoker) invokeRaw(inj *Injector, raw json.RawMessage) (any, error) {
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	return f.Invoke(inj, args)
+}
+
+// jsonDecoder decodes the arguments of a tool with encoding/json, without
+// going through a map. It's built once per tool.
+type jsonDecoder struct {
+	t reflect.Type
+	// keys are the names of all the properties of the arguments
+	keys map[string]bool
+	// check validates the constraints encoding/json doesn't, nil if none
+	check checker
+}
+
+// checker appends the schema violations of v, found at path, to errs.
+type checker func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation
+
+// jsonDecoder returns a decoder of arguments of the decodable type t, whose
+// schema is params. It returns nil if the arguments need to be validated as
+// a map, e.g. if they have maps or values of any type, whose keys can't be
+// checked.
+func (c *config) jsonDecoder(t reflect.Type, params schema.Definition) *jsonDecoder {
+	if t == nil {
+		return nil
+	}
+	b := checkBuilder{
+		config:   c,
+		defs:     params.Defs,
+		keys:     make(map[string]bool),
+		checkers: make(map[reflect.Type]*checker),
+	}
+	check, ok := b.build(t, params)
+	if !ok {
+		return nil
+	}
+
+	// encoding/json matches names case insensitively, a key can only be
+	// trusted to be decoded into the field with the same name if no other
+	// name differs from it by case
+	folded := make(map[string]bool, len(b.keys))
+	for key := range b.keys {
+		k := strings.ToLower(key)
+		if folded[k] {
+			return nil
+		}
+		folded[k] = true
+	}
+	return &jsonDecoder{t: t, keys: b.keys, check: check}
+}
+
+// decode decodes raw, reporting false if it must be decoded as a map
+// instead, e.g. if it has nulls, names which differ by case or values only
+// the Lenient policy accepts.
+func (d *jsonDecoder) decode(raw json.RawMessage) (reflect.Value, bool, error) {
+	if !scanKeys(raw, d.keys) {
+		return reflect.Value{}, false, nil
+	}
+
+	ptr := reflect.New(d.t)
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ptr.Interface()); err != nil {
+		return reflect.Value{}, false, nil
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// trailing data, reported by decodeArgs
+		return reflect.Value{}, false, nil
+	}
+
+	v := ptr.Elem()
+	if d.check != nil {
+		if errs := d.check(v, "", nil); errs != nil {
+			return reflect.Value{}, true, &ArgumentError{Violations: errs}
+		}
+	}
+	return v, true, nil
+}
+
+// scanKeys reports whether raw is an object without nulls, whose keys, at
+// any depth, are all in keys and unescaped.
+func scanKeys(raw []byte, keys map[string]bool) bool {
+	data := bytes.TrimSpace(raw)
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			end, escaped := i+1, false
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					escaped = true
+					end++
+				}
+				end++
+			}
+			if end >= len(data) {
+				return false
+			}
+
+			next := end + 1
+			for next < len(data) && isSpace(data[next]) {
+				next++
+			}
+			if next < len(data) && data[next] == ':' && (escaped || !keys[string(data[i+1:end])]) {
+				return false
+			}
+			i = end
+
+		case 'n':
+			// the only literal starting with n outside strings
+			return false
+		}
+	}
+	return true
+}
+
+// checkBuilder builds the checker of a type, walking it along with its
+// schema.
+type checkBuilder struct {
+	*config
+	defs     map[string]schema.Definition
+	keys     map[string]bool
+	checkers map[reflect.Type]*checker
+}
+
+// build returns the checker of t, nil if it has no constraints, and false if
+// t can't be validated after being decoded.
+func (b *checkBuilder) build(t reflect.Type, d schema.Definition) (checker, bool) {
+	d, ok := b.resolve(d)
+	if !ok || implements(t, jsonSchemerType) {
+		return nil, false
+	}
+
+	switch {
+	case t == reflect.TypeFor[time.Time](), t == reflect.TypeFor[[]byte](), implements(t, textUnmarshalerType):
+		// decoded from strings, and validated while being parsed, unless
+		// constrained by tags or docs
+		base := newTypeDefs(b.config).typeDefinition(t)
+		d.Description, base.Description = "", ""
+		return nil, reflect.DeepEqual(d, base)
+	case implements(t, jsonUnmarshalerType):
+		// anything could be in its JSON
+		return nil, false
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, ok := b.build(t.Elem(), d)
+		if elem == nil {
+			return nil, ok
+		}
+		return func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation {
+			if v.IsNil() {
+				return errs
+			}
+			return elem(v.Elem(), path, errs)
+		}, true
+
+	case reflect.Struct:
+		return b.buildStruct(t, d)
+
+	case reflect.Slice:
+		if d.Items == nil || d.Enum != nil || d.Const != nil {
+			return nil, false
+		}
+		items, ok := b.build(t.Elem(), *d.Items)
+		if !ok {
+			return nil, false
+		}
+		bounded := d.MinItems != nil || d.MaxItems != nil || d.UniqueItems
+		if items == nil && !bounded {
+			return nil, true
+		}
+		arr := schema.Definition{Type: schema.Array, MinItems: d.MinItems, MaxItems: d.MaxItems, UniqueItems: d.UniqueItems}
+		return func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation {
+			if bounded {
+				vals := make([]any, v.Len())
+				for i := range vals {
+					vals[i] = v.Index(i).Interface()
+				}
+				errs = validateAt(&arr, vals, path, errs)
+			}
+			if items != nil {
+				for i := 0; i < v.Len(); i++ {
+					errs = items(v.Index(i), path+"/"+strconv.Itoa(i), errs)
+				}
+			}
+			return errs
+		}, true
+
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !constrained(d) {
+			return nil, true
+		}
+		return func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation {
+			return validateAt(&d, scalar(v), path, errs)
+		}, true
+	}
+
+	// maps and values of any type hold arbitrary keys
+	return nil, false
+}
+
+func (b *checkBuilder) buildStruct(t reflect.Type, d schema.Definition) (checker, bool) {
+	if c, ok := b.checkers[t]; ok {
+		// recursive, or already built
+		return func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation {
+			if *c == nil {
+				return errs
+			}
+			return (*c)(v, path, errs)
+		}, true
+	}
+	c := new(checker)
+	b.checkers[t] = c
+
+	type fieldCheck struct {
+		index []int
+		path  string
+		check checker
+	}
+	var checks []fieldCheck
+	for _, f := range structFields(t) {
+		b.keys[f.name] = true
+		i := slices.IndexFunc(d.Properties, func(p schema.Property) bool { return p.Name == f.name })
+		if i < 0 {
+			return nil, false
+		}
+		check, ok := b.build(f.Type, d.Properties[i].Definition)
+		if !ok {
+			return nil, false
+		}
+		if check != nil {
+			checks = append(checks, fieldCheck{f.Index, pointerPath(f.name), check})
+		}
+	}
+	if checks == nil {
+		return nil, true
+	}
+
+	*c = func(v reflect.Value, path string, errs []schema.Violation) []schema.Violation {
+		for _, fc := range checks {
+			if fv, ok := fieldAt(v, fc.index); ok {
+				errs = fc.check(fv, path+fc.path, errs)
+			}
+		}
+		return errs
+	}
+	return *c, true
+}
+
+// resolve returns d, following references and without a null alternative.
+func (b *checkBuilder) resolve(d schema.Definition) (schema.Definition, bool) {
+	for {
+		switch {
+		case d.Ref != "":
+			def, ok := b.defs[strings.TrimPrefix(d.Ref, "#/$defs/")]
+			if !ok {
+				return d, false
+			}
+			d = def
+		case len(d.AnyOf) == 2 && d.AnyOf[1].Type == schema.Null:
+			d = d.AnyOf[0]
+		case d.AnyOf != nil || d.OneOf != nil:
+			return d, false
+		default:
+			return d, true
+		}
+	}
+}
+
+// constrained reports whether d restricts values further than their type.
+func constrained(d schema.Definition) bool {
+	return d.Enum != nil || d.Const != nil ||
+		d.Minimum != nil || d.Maximum != nil || d.ExclusiveMinimum != nil || d.ExclusiveMaximum != nil || d.MultipleOf != nil ||
+		d.MinLength != nil || d.MaxLength != nil || d.Pattern != "" || d.Format != ""
+}
+
+// scalar returns v as the validator expects it.
+func scalar(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// fieldAt returns the field of v at index, false if it's in a nil embedded
+// struct.
+func fieldAt(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func validateAt(d *schema.Definition, v any, path string, errs []schema.Violation) []schema.Violation {
+	err := d.Validate(v)
+	if err == nil {
+		return errs
+	}
+	verr, ok := err.(schema.ValidationError)
+	if !ok {
+		return append(errs, schema.Violation{Path: path, Message: err.Error()})
+	}
+	for _, v := range verr {
+		errs = append(errs, schema.Violation{Path: path + v.Path, Message: v.Message})
+	}
+	return errs
+}
+
+// decodeArgs decodes the JSON arguments of a tool call.
+func decodeArgs(raw json.RawMessage) (map[string]any, error) {
+	var args map[string]any
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return args, nil
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, argError("", err.Error())
+	}
+	return args, nil
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// structField is a field of a struct, possibly promoted from an embedded
+// struct. Its Index is relative to the outer struct.
+type structField struct {
+	reflect.StructField
+	name string
+	// owner is the struct declaring the field
+	owner reflect.Type
+	// tagged is set if the name comes from a tag
+	tagged bool
+	// inlined is set if the field is promoted through an inline tag, which
+	// encoding/json doesn't support
+	inlined bool
+}
+
+// structFields returns the fields of t the way encoding/json sees them:
+// fields of embedded structs, and of fields tagged inline, are promoted to
+// t. A field shadows deeper ones with the same name; of several at the same
+// depth, the only tagged one wins, otherwise none of them is used.
+func structFields(t reflect.Type) []structField {
+	type embedded struct {
+		t       reflect.Type
+		index   []int
+		inlined bool
+	}
+
+	var fields []structField
+	shadowed := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+	for next := []embedded{{t: t}}; len(next) > 0; {
+		current := next
+		next = nil
+
+		var found []structField
+		for _, e := range current {
+			if visited[e.t] {
+				continue
+			}
+			for i := 0; i < e.t.NumField(); i++ {
+				f := e.t.Field(i)
+				index := append(slices.Clone(e.index), i)
+
+				if et, inlined, ok := inlineStruct(f); ok {
+					if !f.IsExported() && f.Type.Kind() == reflect.Pointer {
+						// can't be allocated
+						continue
+					}
+					next = append(next, embedded{et, index, e.inlined || inlined})
+					continue
+				}
+				if !f.IsExported() {
+					continue
+				}
+
+				name := fieldName(f)
+				if name == "" {
+					continue
+				}
+				f.Index = index
+				found = append(found, structField{
+					StructField: f,
+					name:        name,
+					owner:       e.t,
+					tagged:      tagName(f) != "",
+					inlined:     e.inlined,
+				})
+			}
+		}
+		for _, e := range current {
+			visited[e.t] = true
+		}
+
+		byName := map[string][]structField{}
+		for _, f := range found {
+			byName[f.name] = append(byName[f.name], f)
+		}
+		for name, fs := range byName {
+			if shadowed[name] {
+				continue
+			}
+			shadowed[name] = true
+
+			if len(fs) > 1 {
+				fs = slices.DeleteFunc(fs, func(f structField) bool { return !f.tagged })
+			}
+			if len(fs) == 1 {
+				fields = append(fields, fs[0])
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b structField) int {
+		return slices.Compare(a.Index, b.Index)
+	})
+	return fields
+}
+
+// inlineStruct returns the struct whose fields f promotes, if f is an
+// embedded struct without a name tag or is tagged inline, e.g.
+// `json:",inline"`.
+func inlineStruct(f reflect.StructField) (t reflect.Type, inlined, ok bool) {
+	inlined = hasTagOption(f, "inline")
+	if !inlined && (!f.Anonymous || tagName(f) != "") {
+		return nil, false, false
+	}
+
+	t = f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false, false
+	}
+	return t, inlined, true
+}
+
+// tagName returns the name set in the llm or json tag of f.
+func tagName(f reflect.StructField) string {
+	if tag := f.Tag.Get("llm"); tag != "" {
+		name, _, _ := strings.Cut(tag, ",")
+		return name
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+func hasTagOption(f reflect.StructField, opt string) bool {
+	for _, key := range []string{"llm", "json"} {
+		_, opts, _ := strings.Cut(f.Tag.Get(key), ",")
+		for _, o := range strings.Split(opts, ",") {
+			if strings.TrimSpace(o) == opt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldByIndex returns the field of v at index, allocating the nil embedded
+// structs on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
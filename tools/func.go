@@ -1,11 +1,13 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
+	"runtime/debug"
 	"strings"
 
 	"github.com/byte-sat/llum-tools/schema"
@@ -16,7 +18,7 @@ var argRegex = regexp.MustCompile(`(?m)^([a-zA-Z_][a-zA-Z0-9_]*): (.+)$`)
 
 var errType = reflect.TypeFor[error]()
 
-func codocFunc(inj *Injector, fn any) (schema.Function, invoker) {
+func codocFunc(inj *Injector, fn any, c *config) (schema.Function, invoker) {
 	fnv := reflect.ValueOf(fn)
 	if fnv.Kind() != reflect.Func {
 		panic("fn must be a function")
@@ -42,8 +44,14 @@ func codocFunc(inj *Injector, fn any) (schema.Function, invoker) {
 		return r
 	}, fullName)
 
-	fullName = removeAfter(fullName, "-")  // remove closure prefix (usually -fm)
-	fullName = removeAfter(fullName, "..") // remove cgo closure prefix (usually ..thunkN)
+	// the package path may contain dashes and dots, only the name is trimmed
+	pkgPath, fnName := "", fullName
+	if i := strings.LastIndex(fullName, "/"); i != -1 {
+		pkgPath, fnName = fullName[:i+1], fullName[i+1:]
+	}
+	fnName = removeAfter(fnName, "-")  // remove closure prefix (usually -fm)
+	fnName = removeAfter(fnName, "..") // remove cgo closure prefix (usually ..thunkN)
+	fullName = pkgPath + fnName
 
 	name := filepath.Ext(fullName)[1:]
 
@@ -76,22 +84,23 @@ func codocFunc(inj *Injector, fn any) (schema.Function, invoker) {
 
 	argNo := fnt.NumIn() - len(injected)
 	argNames := make([]string, 0, argNo)
+	argTypes := make([]reflect.Type, 0, argNo)
 	argConverters := make([]argConverter, 0, argNo)
-	args := make([]schema.Property, 0, argNo)
+	names := newObjectNormalizer(c.foldNames)
+	renames := c.foldNames
 
 	for i := len(injected); i < fnt.NumIn(); i++ {
 		it := fnt.In(i)
 		name := doc.Args[i]
 
-		def := typeDefinition(it)
-		def.Description = argDescs[name]
+		aliases := docAliases(argDescs[name])
+		n := c.normalizer(it)
+		names.add(name, aliases, n)
+		renames = renames || aliases != nil || n != nil
 
 		argNames = append(argNames, name)
-		argConverters = append(argConverters, converter(it))
-		args = append(args, schema.Property{
-			Name:       name,
-			Definition: def,
-		})
+		argTypes = append(argTypes, it)
+		argConverters = append(argConverters, c.converter(it))
 	}
 
 	var outfn func([]reflect.Value) (any, error)
@@ -122,19 +131,26 @@ func codocFunc(inj *Injector, fn any) (schema.Function, invoker) {
 	fschema := schema.Function{
 		Name:        name,
 		Description: desc,
-		Parameters: schema.Definition{
-			Type:       schema.Object,
-			Properties: args,
-			Required:   argNames,
-		},
+		Parameters:  c.parameters(argNames, argTypes, argDescs, false),
 	}
 
-	if len(argNames) == 0 {
-		fschema.Parameters = schema.Definition{}
+	params := c.parameters(argNames, argTypes, argDescs, true)
+
+	var normalize normalizer
+	var dec *jsonDecoder
+	if renames {
+		normalize = names.normalize
+	} else {
+		dec = c.jsonDecoder(c.argsStruct(argNames, argTypes), params)
 	}
 
 	return fschema, &codocFuncInvoker{
+		json:          dec,
+		normalize:     normalize,
+		params:        params,
+		lenient:       c.lenient(),
 		injected:      injected,
+		inj:           inj,
 		argConverters: argConverters,
 		outfn:         outfn,
 		argNames:      argNames,
@@ -142,6 +158,37 @@ func codocFunc(inj *Injector, fn any) (schema.Function, invoker) {
 	}
 }
 
+// parameters returns the schema of the arguments of a function. closed
+// makes the objects generated from structs reject unknown properties, for
+// validating the arguments, as the converters would reject them anyway.
+func (c *config) parameters(names []string, types []reflect.Type, descs map[string]string, closed bool) schema.Definition {
+	if len(names) == 0 {
+		return schema.Definition{}
+	}
+
+	defs := newTypeDefs(c)
+	defs.closed = closed
+	props := make([]schema.Property, len(names))
+	for i, name := range names {
+		def := defs.typeDefinition(types[i])
+		def.Description = docConstraints(&def, descs[name])
+		props[i] = schema.Property{Name: name, Definition: def}
+	}
+
+	params := schema.Definition{
+		Type:       schema.Object,
+		Properties: props,
+		Required:   names,
+	}
+	if c.strictSchema || closed {
+		params.AdditionalProperties = schema.AllowAdditional(false)
+	}
+	if len(defs.defs) > 0 {
+		params.Defs = defs.defs
+	}
+	return params
+}
+
 // removeAfter removes the substring after the first occurrence of x in s
 func removeAfter(s string, x string) string {
 	if idx := strings.Index(s, x); idx != -1 {
@@ -151,25 +198,33 @@ func removeAfter(s string, x string) string {
 }
 
 type codocFuncInvoker struct {
+	// json decodes the arguments for InvokeJSON, nil if they need
+	// converting
+	json          *jsonDecoder
+	params        schema.Definition
+	lenient       bool
 	injected      []reflect.Type
 	inj           *Injector
 	argConverters []argConverter
 	argNames      []string
 	outfn         func([]reflect.Value) (any, error)
 	fnv           reflect.Value
+	// normalize renames aliased arguments, nil if there are none
+	normalize normalizer
 }
 
-func (f *codocFuncInvoker) Invoke(inj *Injector, args map[string]any) (any, error) {
+// injector returns inj falling back to the injector the tool was added with.
+func (f *codocFuncInvoker) injector(inj *Injector) *Injector {
 	if inj == nil {
-		inj = f.inj
-	} else {
-		i := *inj
-		i.parent = f.inj
-		inj = &i
+		return f.inj
 	}
 
-	visited := map[string]bool{}
-	vals := make([]reflect.Value, 0, len(f.argNames)+len(f.injected))
+	i := *inj
+	i.parent = f.inj
+	return &i
+}
+
+func (f *codocFuncInvoker) appendInjected(vals []reflect.Value, inj *Injector) ([]reflect.Value, error) {
 	for _, it := range f.injected {
 		val, err := inj.get(it)
 		if err != nil {
@@ -177,24 +232,78 @@ func (f *codocFuncInvoker) Invoke(inj *Injector, args map[string]any) (any, erro
 		}
 		vals = append(vals, reflect.ValueOf(val))
 	}
+	return vals, nil
+}
+
+func (f *codocFuncInvoker) Invoke(inj *Injector, args map[string]any) (any, error) {
+	inj = f.injector(inj)
+	if f.normalize != nil && args != nil {
+		args = f.normalize(args).(map[string]any)
+	}
+	if args != nil {
+		// e.g. maps, sent as strings to Gemini
+		args = f.params.DecodeStrings(args).(map[string]any)
+	}
+
+	if err := f.validate(args); err != nil {
+		return nil, err
+	}
+
+	var errs []schema.Violation
+	visited := map[string]bool{}
+	vals := make([]reflect.Value, 0, len(f.argNames)+len(f.injected))
+	vals, err := f.appendInjected(vals, inj)
+	if err != nil {
+		return nil, err
+	}
 	for i, name := range f.argNames {
+		visited[name] = true
 		arg, ok := args[name]
 		if !ok {
-			return nil, fmt.Errorf("missing argument: %s", name)
+			errs = append(errs, schema.Violation{Path: pointerPath(name), Message: "missing required property"})
+			continue
 		}
 		val, err := f.argConverters[i](arg)
 		if err != nil {
-			return nil, err
+			errs = appendArgError(errs, pointerPath(name), err)
+			continue
 		}
 		vals = append(vals, val)
-		visited[name] = true
 	}
-	for name := range args {
+	for _, name := range sortedKeys(args) {
 		if !visited[name] {
-			return nil, fmt.Errorf("unexpected argument: %s", name)
+			errs = append(errs, schema.Violation{Path: pointerPath(name), Message: "unexpected property"})
 		}
+	}
+	if errs != nil {
+		return nil, &ArgumentError{Violations: errs}
+	}
+
+	return f.call(vals)
+}
 
+// validate checks args against the schema of the function.
+func (f *codocFuncInvoker) validate(args map[string]any) error {
+	validate := f.params.Validate
+	if f.lenient {
+		validate = f.params.ValidateLenient
 	}
+	if err := validate(args); err != nil {
+		var verr schema.ValidationError
+		if errors.As(err, &verr) {
+			return &ArgumentError{Violations: verr}
+		}
+		return fmt.Errorf("%w: %w", ErrInvalidArguments, err)
+	}
+	return nil
+}
+
+func (f *codocFuncInvoker) call(vals []reflect.Value) (out any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			out, err = nil, &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
 
 	outs := f.fnv.Call(vals)
 	return f.outfn(outs)
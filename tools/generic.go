@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// Description sets the description of a tool added with Register.
+func Description(desc string) Option {
+	return func(c *config) { c.description = desc }
+}
+
+// Register adds a tool taking its arguments as the fields of the struct
+// Args. The schema is derived from Args once. JSON arguments are decoded
+// straight into Args with encoding/json when it decodes them like the
+// converters would; otherwise, and for arguments given as a map, they are
+// validated and converted like those of the functions added with Add. The
+// context is the one provided by the injector the tool is invoked with, if
+// any.
+func Register[Args, Result any](r *Repo, name string, fn func(context.Context, Args) (Result, error), opts ...Option) error {
+	t := reflect.TypeFor[Args]()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("arguments of %s must be a struct, got %s", name, t)
+	}
+
+	cfg := r.cfg
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	g := &genericInvoker[Args, Result]{
+		fn:        fn,
+		inj:       r.inj,
+		params:    cfg.structParameters(t, true),
+		lenient:   cfg.lenient(),
+		normalize: cfg.normalizer(t),
+		conv:      cfg.converter(t),
+	}
+	if g.normalize == nil && cfg.decodable(t, map[reflect.Type]bool{}) {
+		g.json = cfg.jsonDecoder(t, g.params)
+	}
+
+	r.register(schema.Function{
+		Name:        name,
+		Description: cfg.description,
+		Parameters:  cfg.structParameters(t, false),
+	}, g, cfg)
+	return nil
+}
+
+// structParameters returns the schema of the struct t holding the
+// arguments of a tool, closed like the parameters of functions.
+func (c *config) structParameters(t reflect.Type, closed bool) schema.Definition {
+	defs := newTypeDefs(c)
+	defs.closed = closed
+	params := defs.structDefinition(t)
+	if len(defs.defs) > 0 {
+		params.Defs = defs.defs
+	}
+	return params
+}
+
+type genericInvoker[Args, Result any] struct {
+	fn  func(context.Context, Args) (Result, error)
+	inj *Injector
+	// params is the schema the arguments are validated against before
+	// being converted
+	params  schema.Definition
+	lenient bool
+	conv    argConverter
+	// normalize renames aliased arguments, nil if there are none
+	normalize normalizer
+	// json decodes the arguments for InvokeJSON, nil if they need
+	// converting
+	json *jsonDecoder
+}
+
+var contextType = reflect.TypeFor[context.Context]()
+
+func (g *genericInvoker[Args, Result]) Invoke(inj *Injector, args map[string]any) (any, error) {
+	if g.normalize != nil && args != nil {
+		args = g.normalize(args).(map[string]any)
+	}
+	if args != nil {
+		// e.g. maps, sent as strings to Gemini
+		args = g.params.DecodeStrings(args).(map[string]any)
+	}
+
+	validate := g.params.Validate
+	if g.lenient {
+		validate = g.params.ValidateLenient
+	}
+	if err := validate(args); err != nil {
+		var verr schema.ValidationError
+		if errors.As(err, &verr) {
+			return nil, &ArgumentError{Violations: verr}
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArguments, err)
+	}
+
+	var val any = args
+	if args == nil {
+		val = map[string]any{}
+	}
+	rv, err := g.conv(val)
+	if err != nil {
+		return nil, &ArgumentError{Violations: appendArgError(nil, "", err)}
+	}
+	return g.call(inj, rv.Interface().(Args))
+}
+
+func (g *genericInvoker[Args, Result]) InvokeJSON(inj *Injector, raw json.RawMessage) (any, error) {
+	if g.json != nil {
+		v, ok, err := g.json.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return g.call(inj, v.Interface().(Args))
+		}
+	}
+
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	return g.Invoke(inj, args)
+}
+
+func (g *genericInvoker[Args, Result]) call(inj *Injector, args Args) (out any, err error) {
+	ctx := context.Background()
+	if inj == nil {
+		inj = g.inj
+	} else {
+		i := *inj
+		i.parent = g.inj
+		inj = &i
+	}
+	if inj.has(contextType) {
+		v, err := inj.get(contextType)
+		if err != nil {
+			return nil, err
+		}
+		ctx = v.(context.Context)
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			out, err = nil, &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return g.fn(ctx, args)
+}
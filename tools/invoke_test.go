@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/noonien/codoc"
+)
+
+type benchFilter struct {
+	Field string   `json:"field"`
+	Op    string   `json:"op"`
+	Value []string `json:"value"`
+}
+
+type benchQuery struct {
+	Text    string        `json:"text"`
+	Limit   int           `json:"limit"`
+	Filters []benchFilter `json:"filters"`
+}
+
+func benchSearch(query benchQuery, exact bool) int {
+	return len(query.Filters)
+}
+
+func init() {
+	codoc.Register(codoc.Package{
+		ID:   "github.com/byte-sat/llum-tools/tools",
+		Name: "tools",
+		Functions: map[string]codoc.Function{
+			"benchSearch": {
+				Name: "benchSearch",
+				Doc:  "benchSearch searches things.\n\nquery: what to search for\nexact: whether to match exactly",
+				Args: []string{"query", "exact"},
+			},
+		},
+	})
+}
+
+var benchArgs = json.RawMessage(`{
+	"query": {
+		"text": "llama",
+		"limit": 20,
+		"filters": [
+			{"field": "kind", "op": "in", "value": ["animal", "mammal"]},
+			{"field": "region", "op": "eq", "value": ["andes"]},
+			{"field": "color", "op": "in", "value": ["white", "brown", "black"]}
+		]
+	},
+	"exact": false
+}`)
+
+func benchRepo(b *testing.B) *Repo {
+	r, err := New(nil, benchSearch)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return r
+}
+
+// BenchmarkInvoke decodes the arguments to a map and converts them.
+func BenchmarkInvoke(b *testing.B) {
+	r := benchRepo(b)
+	b.ReportAllocs()
+	for range b.N {
+		var args map[string]any
+		if err := json.Unmarshal(benchArgs, &args); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.Invoke(nil, "benchSearch", args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInvokeJSON decodes the arguments straight into their types.
+func BenchmarkInvokeJSON(b *testing.B) {
+	r := benchRepo(b)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := r.InvokeJSON(nil, "benchSearch", benchArgs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package tools
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+const (
+	// DefaultPageTTL is how long the rest of a paginated result is kept
+	// around, unless set with PageTTL.
+	DefaultPageTTL = time.Hour
+	// maxPages is the most paginated results kept, the oldest are dropped
+	// first.
+	maxPages = 1000
+)
+
+// NextPageTool is the name of the built-in tool returning the next page of a
+// paginated result.
+const NextPageTool = "NextPage"
+
+// Truncated is returned in place of a result larger than the maximum result
+// size.
+type Truncated struct {
+	Truncated bool `json:"truncated"`
+	Result    any  `json:"result"`
+	// Cursor can be passed to the NextPage tool to get the rest of the
+	// result.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// MaxResultSize limits the size of tool results to about n bytes of JSON.
+// Larger results are truncated. Zero means no limit.
+func MaxResultSize(n int) Option {
+	return func(c *config) { c.maxResultSize = n }
+}
+
+// Paginate makes truncated string, array and object results return a
+// cursor, which the model can use to request the rest of the result with
+// the NextPage tool.
+func Paginate() Option {
+	return func(c *config) { c.paginate = true }
+}
+
+// PageTTL sets how long the rest of a paginated result is kept around,
+// DefaultPageTTL by default. At most 1000 results are kept, the oldest are
+// dropped first.
+func PageTTL(d time.Duration) Option {
+	return func(c *config) { c.pageTTL = d }
+}
+
+// pagination returns how long the pages of results are kept, zero if they
+// aren't paginated.
+func (c *config) pagination() time.Duration {
+	switch {
+	case !c.paginate:
+		return 0
+	case c.pageTTL > 0:
+		return c.pageTTL
+	}
+	return DefaultPageTTL
+}
+
+func (r *Repo) limit(c *config, v any) (any, error) {
+	if c.maxResultSize <= 0 || v == nil {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) <= c.maxResultSize {
+		return v, nil
+	}
+
+	var val any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+
+	switch val.(type) {
+	case string, []any, map[string]any:
+		return r.pages.page(val, 0, c.maxResultSize, c.pagination()), nil
+	}
+	return Truncated{Truncated: true, Result: truncate(val, c.maxResultSize)}, nil
+}
+
+// truncate trims strings and arrays in v so its JSON encoding takes about
+// max bytes.
+func truncate(v any, max int) any {
+	switch v := v.(type) {
+	case string:
+		return cutString(v, max)
+
+	case []any:
+		out := make([]any, 0, len(v))
+		left := max - 2
+		for _, e := range v {
+			size := jsonSize(e) + 1
+			if size > left {
+				if e = truncate(e, left-1); jsonSize(e) < left {
+					out = append(out, e)
+				}
+				break
+			}
+			out = append(out, e)
+			left -= size
+		}
+		return out
+
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out := make(map[string]any, len(v))
+		left := max - 2
+		for _, k := range keys {
+			ks := jsonSize(k) + 2
+			if ks >= left {
+				break
+			}
+			e := v[k]
+			if size := jsonSize(e); size > left-ks {
+				out[k] = truncate(e, left-ks)
+				break
+			}
+			out[k] = e
+			left -= ks + jsonSize(e)
+		}
+		return out
+
+	default:
+		return v
+	}
+}
+
+// cutString returns the longest prefix of s whose JSON encoding takes at
+// most max bytes.
+func cutString(s string, max int) string {
+	size := 2 // quotes
+	for i, r := range s {
+		size += jsonRuneSize(r, s[i:])
+		if size > max {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// jsonRuneSize returns the size of r, found at the start of s, in a JSON
+// string as encoded by encoding/json.
+func jsonRuneSize(r rune, s string) int {
+	switch {
+	case r == '"' || r == '\\' || r == '\n' || r == '\r' || r == '\t':
+		return 2
+	case r < 0x20 || r == '<' || r == '>' || r == '&' || r == '\u2028' || r == '\u2029':
+		return 6
+	case r == utf8.RuneError:
+		if _, n := utf8.DecodeRuneInString(s); n == 1 {
+			return 6 // invalid bytes become \ufffd
+		}
+	}
+	return utf8.RuneLen(r)
+}
+
+func jsonSize(v any) int {
+	b, _ := json.Marshal(v)
+	return len(b)
+}
+
+type page struct {
+	id      string
+	val     any
+	offset  int
+	max     int
+	ttl     time.Duration
+	expires time.Time
+}
+
+// pageStore keeps the remainder of paginated results.
+type pageStore struct {
+	mu    sync.Mutex
+	pages map[string]*list.Element
+	// order holds the pages, oldest first
+	order list.List
+}
+
+// page returns the part of val, a string, an array or an object, starting
+// at offset. Objects are paged by their sorted keys. The rest is kept for
+// ttl, if not zero.
+func (ps *pageStore) page(val any, offset, max int, ttl time.Duration) Truncated {
+	var res any
+	var next, total int
+	switch val := val.(type) {
+	case string:
+		s := cutString(val[offset:], max)
+		if s == "" {
+			// always make progress
+			_, n := utf8.DecodeRuneInString(val[offset:])
+			s = val[offset : offset+n]
+		}
+		res, next, total = s, offset+len(s), len(val)
+
+	case []any:
+		arr := truncate(val[offset:], max).([]any)
+		if len(arr) == 0 && offset < len(val) {
+			arr = val[offset : offset+1]
+		}
+		res, next, total = arr, offset+len(arr), len(val)
+
+	case map[string]any:
+		keys := sortedKeys(val)
+		obj := make(map[string]any)
+		left := max - 2
+		next = offset
+		for _, k := range keys[offset:] {
+			size := jsonSize(k) + jsonSize(val[k]) + 2
+			if size > left && len(obj) > 0 {
+				break
+			}
+			// always make progress
+			obj[k] = val[k]
+			left -= size
+			next++
+		}
+		res, total = obj, len(keys)
+	}
+
+	tr := Truncated{Truncated: next < total, Result: res}
+	if tr.Truncated && ttl > 0 {
+		tr.Cursor = ps.put(&page{val: val, offset: next, max: max, ttl: ttl})
+	}
+	return tr
+}
+
+func (ps *pageStore) put(p *page) string {
+	var b [16]byte
+	rand.Read(b[:])
+	p.id = hex.EncodeToString(b[:])
+
+	now := time.Now()
+	p.expires = now.Add(p.ttl)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.pages == nil {
+		ps.pages = make(map[string]*list.Element)
+	}
+	for e := ps.order.Front(); e != nil; {
+		next := e.Next()
+		if now.After(e.Value.(*page).expires) {
+			ps.remove(e)
+		}
+		e = next
+	}
+	for ps.order.Len() >= maxPages {
+		ps.remove(ps.order.Front())
+	}
+	ps.pages[p.id] = ps.order.PushBack(p)
+	return p.id
+}
+
+func (ps *pageStore) remove(e *list.Element) {
+	delete(ps.pages, e.Value.(*page).id)
+	ps.order.Remove(e)
+}
+
+func (ps *pageStore) next(cursor string) (Truncated, error) {
+	ps.mu.Lock()
+	e, ok := ps.pages[cursor]
+	if ok {
+		ps.remove(e)
+	}
+	ps.mu.Unlock()
+
+	if !ok || time.Now().After(e.Value.(*page).expires) {
+		return Truncated{}, argError("/cursor", "unknown or expired cursor")
+	}
+	p := e.Value.(*page)
+	return ps.page(p.val, p.offset, p.max, p.ttl), nil
+}
+
+// nextPageInvoker implements the NextPage tool.
+type nextPageInvoker struct {
+	pages *pageStore
+}
+
+var nextPageSchema = schema.Function{
+	Name:        NextPageTool,
+	Description: "Get the next page of a truncated tool result",
+	Parameters: schema.Definition{
+		Type: schema.Object,
+		Properties: schema.Properties{{
+			Name: "cursor",
+			Definition: schema.Definition{
+				Type:        schema.String,
+				Description: "cursor of the truncated result",
+			},
+		}},
+		Required:             []string{"cursor"},
+		AdditionalProperties: schema.AllowAdditional(false),
+	},
+}
+
+func (n nextPageInvoker) Invoke(_ *Injector, args map[string]any) (any, error) {
+	if err := nextPageSchema.Parameters.Validate(args); err != nil {
+		return nil, &ArgumentError{Violations: err.(schema.ValidationError)}
+	}
+	return n.pages.next(args["cursor"].(string))
+}
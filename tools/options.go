@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"reflect"
+	"time"
+)
+
+// config holds the settings a tool is registered with. A Repo keeps a
+// default config which every tool added after it starts from.
+type config struct {
+	async         bool
+	renderer      Renderer
+	maxResultSize int
+	paginate      bool
+	pageTTL       time.Duration
+	strictSchema  bool
+	enums         map[reflect.Type]*enum
+	unions        map[reflect.Type]*union
+	types         map[reflect.Type]typeMapping
+	inlineSchema  bool
+	nullableAnyOf bool
+	policy        Policy
+	foldNames     bool
+	openAIStrict  bool
+	description   string
+
+	// converters of the structs being built, to handle recursive types
+	building map[reflect.Type]*argConverter
+}
+
+// Option configures a Repo or a single tool.
+//
+// Passed to New, an Option changes the defaults for the tools that follow
+// it. Passed to Func or Repo.Add, it only affects that tool.
+type Option func(*config)
+
+// Async marks tools to be run as background jobs instead of inline with
+// the request that invoked them.
+func Async() Option {
+	return func(c *config) { c.async = true }
+}
+
+// StrictSchema forbids properties not present in the schema of objects
+// generated from structs, by setting additionalProperties to false.
+func StrictSchema() Option {
+	return func(c *config) { c.strictSchema = true }
+}
+
+// InlineSchema inlines the schema of named structs instead of referencing
+// a single definition in $defs, for providers that don't support $ref.
+// Recursive structs are still referenced.
+func InlineSchema() Option {
+	return func(c *config) { c.inlineSchema = true }
+}
+
+// NullableAnyOf makes pointers nullable using anyOf with a null schema,
+// instead of adding null to their type.
+func NullableAnyOf() Option {
+	return func(c *config) { c.nullableAnyOf = true }
+}
+
+type funcWithOptions struct {
+	fn   any
+	opts []Option
+}
+
+// Func wraps fn with tool specific options, for use with New.
+func Func(fn any, opts ...Option) any {
+	return funcWithOptions{fn: fn, opts: opts}
+}
+
+// OpenAIStrict rewrites the schema of tools for OpenAI strict mode with
+// schema.Strict. Tools which can't be made strict are added as they are,
+// and reported by Repo.NonStrict.
+func OpenAIStrict() Option {
+	return func(c *config) { c.openAIStrict = true }
+}
@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Policy decides which argument values are accepted for a type.
+type Policy int
+
+const (
+	// Lenient also accepts numbers and booleans in strings, numbers as
+	// booleans, and numbers and booleans as strings.
+	Lenient Policy = iota
+	// Strict only accepts values of the type given in the schema.
+	Strict
+)
+
+// Conversion sets the policy used to convert arguments. The default is
+// Lenient. Values out of the range of their type, and integers with a
+// fractional part, are always rejected. Objects and arrays encoded as JSON
+// strings are always accepted, as Gemini is told to send maps that way.
+func Conversion(p Policy) Option {
+	return func(c *config) { c.policy = p }
+}
+
+func (c *config) lenient() bool { return c.policy == Lenient }
+
+var (
+	errFraction = errors.New("not an integer")
+	errOverflow = errors.New("out of range")
+	errNegative = errors.New("negative value for unsigned type")
+	errNotNum   = errors.New("not a number")
+)
+
+// 2^63, the first float64 larger than any int64
+const maxInt64Float = float64(1 << 63)
+
+func toInt(val any, lenient bool) (int64, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, errOverflow
+		}
+		return int64(rv.Uint()), nil
+	}
+
+	if n, ok := val.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+	} else if s, ok := val.(string); ok && lenient {
+		if i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+
+	f, err := toFloat(val, lenient)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, errFraction
+	}
+	if f < -maxInt64Float || f >= maxInt64Float || math.IsNaN(f) {
+		return 0, errOverflow
+	}
+	return int64(f), nil
+}
+
+func toUint(val any, lenient bool) (uint64, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, errNegative
+		}
+		return uint64(rv.Int()), nil
+	}
+
+	if n, ok := val.(json.Number); ok {
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u, nil
+		}
+	} else if s, ok := val.(string); ok && lenient {
+		if u, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err == nil {
+			return u, nil
+		}
+	}
+
+	f, err := toFloat(val, lenient)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, errFraction
+	}
+	if f < 0 {
+		return 0, errNegative
+	}
+	if f >= 2*maxInt64Float || math.IsNaN(f) {
+		return 0, errOverflow
+	}
+	return uint64(f), nil
+}
+
+func toFloat(val any, lenient bool) (float64, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	}
+
+	switch v := val.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		if lenient {
+			return strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+	}
+	return 0, fmt.Errorf("%w: %T", errNotNum, val)
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"reflect"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// RegisterType sets the schema of T and how arguments are converted to it,
+// taking precedence over how T would be handled otherwise.
+func RegisterType[T any](def schema.Definition, conv func(any) (T, error)) Option {
+	t := reflect.TypeFor[T]()
+	tm := typeMapping{
+		def: def,
+		conv: func(val any) (reflect.Value, error) {
+			v, err := conv(val)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			return reflect.ValueOf(&v).Elem(), nil
+		},
+	}
+	return func(c *config) { c.types = withKey(c.types, t, tm) }
+}
+
+// mapping returns the schema and converter of t, if it is a registered or
+// well known type.
+func (c *config) mapping(t reflect.Type) (typeMapping, bool) {
+	if tm, ok := c.types[t]; ok {
+		return tm, true
+	}
+	return wellKnownFor(t)
+}
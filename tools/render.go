@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToolResult is implemented by tool results that control their own
+// representation for the model. It takes precedence over any Renderer.
+type ToolResult interface {
+	Render() (string, error)
+}
+
+// Renderer turns a tool result into the text given to the model.
+type Renderer func(v any) (string, error)
+
+// Render sets the renderer for tool results. Without one, results are
+// returned as is.
+func Render(r Renderer) Option {
+	return func(c *config) { c.renderer = r }
+}
+
+func (c *config) render(v any) (any, error) {
+	if tr, ok := v.(ToolResult); ok {
+		return tr.Render()
+	}
+	if c.renderer == nil || v == nil {
+		return v, nil
+	}
+	return c.renderer(v)
+}
+
+// RenderJSON renders results as indented JSON.
+func RenderJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	return string(b), err
+}
+
+// RenderCompactJSON renders results as JSON without any whitespace.
+func RenderCompactJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// RenderText renders results using fmt, which uses String for results
+// implementing fmt.Stringer.
+func RenderText(v any) (string, error) {
+	return fmt.Sprint(v), nil
+}
+
+// RenderYAML renders results as YAML.
+func RenderYAML(v any) (string, error) {
+	val, err := jsonValue(v)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	writeYAML(&buf, val, 0)
+	return buf.String(), nil
+}
+
+// RenderMarkdown renders slices of structs as a Markdown table. Other
+// results are rendered as compact JSON.
+func RenderMarkdown(v any) (string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return RenderCompactJSON(v)
+	}
+
+	et := rv.Type().Elem()
+	for et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return RenderCompactJSON(v)
+	}
+
+	var cols []string
+	var idxs []int
+	for i := 0; i < et.NumField(); i++ {
+		f := et.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		cols = append(cols, name)
+		idxs = append(idxs, i)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("| " + strings.Join(cols, " | ") + " |\n")
+	buf.WriteString(strings.Repeat("| --- ", len(cols)) + "|\n")
+	for i := 0; i < rv.Len(); i++ {
+		row := rv.Index(i)
+		for row.Kind() == reflect.Pointer && !row.IsNil() {
+			row = row.Elem()
+		}
+		if row.Kind() != reflect.Struct {
+			continue
+		}
+
+		buf.WriteString("|")
+		for _, idx := range idxs {
+			cell, err := markdownCell(row.Field(idx).Interface())
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(" " + cell + " |")
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.String(), nil
+}
+
+func markdownCell(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		s = string(b)
+		if s == "null" {
+			s = ""
+		}
+	}
+
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\n", "<br>")
+	return s, nil
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// jsonValue returns v as it would be decoded from its JSON encoding.
+func jsonValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var val any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err = dec.Decode(&val)
+	return val, err
+}
+
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			buf.WriteString(pad + yamlScalar(k) + ":")
+			writeYAMLValue(buf, v[k], indent)
+		}
+
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+
+		for _, e := range v {
+			buf.WriteString(pad + "-")
+			writeYAMLValue(buf, e, indent)
+		}
+
+	default:
+		buf.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLValue writes v after a key or list marker.
+func writeYAMLValue(buf *bytes.Buffer, v any, indent int) {
+	switch e := v.(type) {
+	case map[string]any:
+		if len(e) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, e, indent+1)
+			return
+		}
+		buf.WriteString(" {}\n")
+	case []any:
+		if len(e) > 0 {
+			buf.WriteString("\n")
+			writeYAML(buf, e, indent+1)
+			return
+		}
+		buf.WriteString(" []\n")
+	default:
+		buf.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlain(v) {
+			return v
+		}
+		// JSON strings are valid double quoted YAML scalars
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	return !strings.ContainsAny(s, ":#\n\t\\")
+}
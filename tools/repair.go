@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Repair names a fix applied to malformed JSON by RepairJSON or
+// RepairArguments.
+type Repair string
+
+const (
+	// RepairString means the arguments were a JSON-encoded string instead
+	// of an object.
+	RepairString Repair = "string"
+	// RepairTrailingComma means commas before a closing bracket or brace
+	// were dropped.
+	RepairTrailingComma Repair = "trailing_comma"
+	// RepairSingleQuotes means single-quoted strings were double-quoted.
+	RepairSingleQuotes Repair = "single_quotes"
+)
+
+// RepairArguments fixes the common ways models malform tool arguments. The
+// arguments may be an object or a string holding one. The repairs applied
+// are returned along with the fixed arguments; if data is valid, or can't
+// be fixed, it is returned unchanged with no repairs.
+func RepairArguments(data json.RawMessage) (json.RawMessage, []Repair) {
+	var repairs []Repair
+	fixed := bytes.TrimSpace(data)
+
+	var s string
+	if len(fixed) > 0 && fixed[0] == '"' && json.Unmarshal(fixed, &s) == nil {
+		fixed = bytes.TrimSpace([]byte(s))
+		repairs = append(repairs, RepairString)
+	}
+
+	fixed, more := RepairJSON(fixed)
+	repairs = append(repairs, more...)
+	if !json.Valid(fixed) {
+		return data, nil
+	}
+	return fixed, repairs
+}
+
+// RepairJSON drops trailing commas and double-quotes single-quoted strings
+// in data. Valid JSON is returned unchanged.
+func RepairJSON(data []byte) ([]byte, []Repair) {
+	if json.Valid(data) {
+		return data, nil
+	}
+
+	var (
+		out                  = make([]byte, 0, len(data))
+		commas, singleQuotes bool
+	)
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			end := stringEnd(data, i, '"')
+			if end > len(data) {
+				return data, nil
+			}
+			out = append(out, data[i:end]...)
+			i = end - 1
+		case '\'':
+			end := stringEnd(data, i, '\'')
+			if end > len(data) {
+				return data, nil
+			}
+			out = appendSingleQuoted(out, data[i+1:end-1])
+			singleQuotes = true
+			i = end - 1
+		case ',':
+			j := i + 1
+			for j < len(data) && isSpace(data[j]) {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				commas = true
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	var repairs []Repair
+	if commas {
+		repairs = append(repairs, RepairTrailingComma)
+	}
+	if singleQuotes {
+		repairs = append(repairs, RepairSingleQuotes)
+	}
+	return out, repairs
+}
+
+// stringEnd returns the index just past the string starting with the quote
+// at data[start], or len(data)+1 if it isn't terminated.
+func stringEnd(data []byte, start int, quote byte) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(data) + 1
+}
+
+// appendSingleQuoted appends the body of a single-quoted string to out as a
+// double-quoted one.
+func appendSingleQuoted(out, body []byte) []byte {
+	out = append(out, '"')
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body) && body[i+1] == '\'':
+			out = append(out, '\'')
+			i++
+		case c == '\\' && i+1 < len(body):
+			out = append(out, c, body[i+1])
+			i++
+		case c == '"':
+			out = append(out, '\\', '"')
+		default:
+			out = append(out, c)
+		}
+	}
+	return append(out, '"')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,19 @@
+package tools
+
+// Stream lets a tool report progress and partial results before it
+// returns. Tools get one by taking a Stream as an injected argument.
+type Stream interface {
+	// Progress reports a human readable progress message.
+	Progress(msg string) error
+	// Partial reports a partial result.
+	Partial(v any) error
+}
+
+// Discard is a Stream that drops everything written to it. It is useful as
+// the default provider for clients that don't stream.
+var Discard Stream = discard{}
+
+type discard struct{}
+
+func (discard) Progress(string) error { return nil }
+func (discard) Partial(any) error     { return nil }
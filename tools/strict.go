@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"encoding/json"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// strict returns fn made strict, along with an invoker dropping the nulls
+// models send for optional arguments in strict mode.
+func (r *Repo) strict(fn schema.Function, inv invoker) (schema.Function, invoker) {
+	params, err := schema.Strict(fn.Parameters)
+	if err != nil {
+		if r.nonStrict == nil {
+			r.nonStrict = make(map[string]error)
+		}
+		r.nonStrict[fn.Name] = err
+		return fn, inv
+	}
+	delete(r.nonStrict, fn.Name)
+
+	si := strictInvoker{invoker: inv, params: fn.Parameters}
+	fn.Parameters = params
+	fn.Strict = true
+	return fn, si
+}
+
+// NonStrict returns the tools added with OpenAIStrict which couldn't be
+// made strict, with the reason.
+func (r *Repo) NonStrict() map[string]error {
+	return r.nonStrict
+}
+
+type strictInvoker struct {
+	invoker
+	// params is the schema before being made strict
+	params schema.Definition
+}
+
+func (s strictInvoker) Invoke(inj *Injector, args map[string]any) (any, error) {
+	if args != nil {
+		args = s.params.OmitNulls(args).(map[string]any)
+	}
+	return s.invoker.Invoke(inj, args)
+}
+
+func (s strictInvoker) InvokeJSON(inj *Injector, raw json.RawMessage) (any, error) {
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.Invoke(inj, args)
+}
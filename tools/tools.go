@@ -1,33 +1,54 @@
 package tools
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/byte-sat/llum-tools/schema"
 )
 
 type Repo struct {
-	tools  map[string]invoker
+	tools  map[string]*tool
 	schema []schema.Function
 	inj    *Injector
+	cfg    config
+	pages  pageStore
+	// nonStrict holds why tools couldn't be made strict
+	nonStrict map[string]error
+}
+
+type tool struct {
+	invoker
+	cfg config
 }
 
 type invoker interface {
 	Invoke(*Injector, map[string]any) (any, error)
 }
 
+// New creates a Repo from a list of functions, functions wrapped with Func
+// and Options. Options change the defaults of the functions after them.
 func New(inj *Injector, fns ...any) (*Repo, error) {
 	if inj == nil {
 		inj, _ = Inject()
 	}
 	r := &Repo{
-		tools:  make(map[string]invoker, len(fns)),
+		tools:  make(map[string]*tool, len(fns)),
 		schema: make([]schema.Function, 0, len(fns)),
 		inj:    inj,
 	}
 
 	for _, fn := range fns {
-		if err := r.Add(fn); err != nil {
+		var err error
+		switch fn := fn.(type) {
+		case Option:
+			fn(&r.cfg)
+		case funcWithOptions:
+			err = r.Add(fn.fn, fn.opts...)
+		default:
+			err = r.Add(fn)
+		}
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -35,18 +56,91 @@ func New(inj *Injector, fns ...any) (*Repo, error) {
 	return r, nil
 }
 
-func (r *Repo) Add(fn any) error {
-	schema, invoker := codocFunc(r.inj, fn)
-	r.tools[schema.Name] = invoker
-	r.schema = append(r.schema, schema)
+func (r *Repo) Add(fn any, opts ...Option) error {
+	cfg := r.cfg
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	schema, invoker := codocFunc(r.inj, fn, &cfg)
+	r.register(schema, invoker, cfg)
 	return nil
 }
 
+// register adds a tool, along with the NextPage tool if it paginates.
+func (r *Repo) register(schema schema.Function, invoker invoker, cfg config) {
+	r.add(schema, invoker, cfg)
+
+	if cfg.paginate && r.tools[NextPageTool] == nil {
+		r.add(nextPageSchema, nextPageInvoker{&r.pages}, config{})
+	}
+}
+
+func (r *Repo) add(fn schema.Function, invoker invoker, cfg config) {
+	if cfg.openAIStrict {
+		fn, invoker = r.strict(fn, invoker)
+	}
+	r.tools[fn.Name] = &tool{invoker: invoker, cfg: cfg}
+	r.schema = append(r.schema, fn)
+}
+
 func (r *Repo) Schema() []schema.Function { return r.schema }
 
+// SchemaFor returns the tools in the format of dialect d, leaving out the
+// ones reported by Unsupported.
+func (r *Repo) SchemaFor(d schema.Dialect) (any, error) {
+	return schema.Tools(d, r.schema)
+}
+
+// Unsupported returns the tools which can't be described in dialect d, with
+// the reason.
+func (r *Repo) Unsupported(d schema.Dialect) map[string]error {
+	return schema.Unsupported(d, r.schema)
+}
+
+// IsAsync reports whether the named tool should be run as a job.
+func (r *Repo) IsAsync(name string) bool {
+	t, ok := r.tools[name]
+	return ok && t.cfg.async
+}
+
 func (r *Repo) Invoke(inj *Injector, name string, args map[string]any) (any, error) {
-	if invoker, ok := r.tools[name]; ok {
-		return invoker.Invoke(inj, args)
+	tool, ok := r.tools[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	out, err := tool.Invoke(inj, args)
+	return r.result(tool, out, err)
+}
+
+// InvokeJSON is like Invoke, but takes the arguments as JSON. This avoids
+// converting them from a map when they can be decoded directly.
+func (r *Repo) InvokeJSON(inj *Injector, name string, args json.RawMessage) (any, error) {
+	tool, ok := r.tools[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	var out any
+	var err error
+	if ji, ok := tool.invoker.(jsonInvoker); ok {
+		out, err = ji.InvokeJSON(inj, args)
+	} else {
+		var m map[string]any
+		if m, err = decodeArgs(args); err == nil {
+			out, err = tool.Invoke(inj, m)
+		}
+	}
+	return r.result(tool, out, err)
+}
+
+func (r *Repo) result(tool *tool, out any, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	if out, err = tool.cfg.render(out); err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("tool not found: %s", name)
+	return r.limit(&tool.cfg, out)
 }
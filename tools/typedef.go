@@ -3,16 +3,111 @@ package tools
 import (
 	"fmt"
 	"reflect"
-	"strings"
+	"regexp"
+	"strconv"
 
 	"github.com/byte-sat/llum-tools/schema"
 	"github.com/noonien/codoc"
 )
 
-func typeDefinition(t reflect.Type) schema.Definition {
+// typeDefs builds the schema of the arguments of a function. Named structs
+// are put in $defs and referenced, unless the schema is inlined.
+type typeDefs struct {
+	*config
+	defs map[string]schema.Definition
+	refs map[reflect.Type]string
+	// names reserves the $defs names, before their schema is built
+	names map[string]reflect.Type
+	// structs being inlined, to detect recursion
+	visiting map[reflect.Type]bool
+	// closed makes struct schemas reject unknown properties
+	closed bool
+}
+
+func newTypeDefs(c *config) *typeDefs {
+	return &typeDefs{
+		config:   c,
+		defs:     make(map[string]schema.Definition),
+		refs:     make(map[reflect.Type]string),
+		names:    make(map[string]reflect.Type),
+		visiting: make(map[reflect.Type]bool),
+	}
+}
+
+func (c *typeDefs) typeDefinition(t reflect.Type) schema.Definition {
+	if tm, ok := c.mapping(t); ok {
+		return tm.def
+	}
+	if def, ok := unmarshalerDefinition(t); ok {
+		return def
+	}
+	if e := c.enum(t); e != nil {
+		return e.definition(c.kindDefinition(t))
+	}
+	return c.kindDefinition(t)
+}
+
+// structRef returns the schema of the struct t, either inlined or as a
+// reference to its $defs entry. Recursive structs are always referenced.
+func (c *typeDefs) structRef(t reflect.Type) schema.Definition {
+	if t.Name() == "" || c.inlineSchema && !c.visiting[t] {
+		c.visiting[t] = true
+		defer delete(c.visiting, t)
+		return c.structDefinition(t)
+	}
+
+	name, ok := c.refs[t]
+	if !ok {
+		name = c.defName(t)
+		c.refs[t] = name
+		c.defs[name] = c.structDefinition(t)
+	}
+	return schema.Definition{Ref: "#/$defs/" + name}
+}
+
+// nullable returns def allowing null values as well.
+func (c *typeDefs) nullable(def schema.Definition) schema.Definition {
+	if def.Type == "" || def.Ref != "" || c.nullableAnyOf {
+		return schema.Definition{
+			AnyOf: []schema.Definition{def, {Type: schema.Null}},
+		}
+	}
+
+	def.Nullable = true
+	if len(def.Enum) > 0 {
+		def.Enum = append(def.Enum, nil)
+	}
+	return def
+}
+
+var defNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// defName returns an unused $defs name for t, and reserves it.
+func (c *typeDefs) defName(t reflect.Type) string {
+	base := defNameRegex.ReplaceAllString(t.Name(), "_")
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := c.names[name]; !ok {
+			c.names[name] = t
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+func (c *typeDefs) kindDefinition(t reflect.Type) schema.Definition {
 	switch t.Kind() {
 	case reflect.Pointer:
-		return typeDefinition(t.Elem())
+		return c.nullable(c.typeDefinition(t.Elem()))
+
+	case reflect.Interface:
+		if u, ok := c.unions[t]; ok {
+			return c.unionRef(t, u)
+		}
+		if t.NumMethod() == 0 {
+			return schema.Definition{}
+		}
+		panic(fmt.Sprintf("unsupported argument type %s", t))
 
 	case reflect.Bool:
 		return schema.Definition{
@@ -21,9 +116,11 @@ func typeDefinition(t reflect.Type) schema.Definition {
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return schema.Definition{
+		def := schema.Definition{
 			Type: schema.Integer,
 		}
+		intRange(&def, t)
+		return def
 
 	case reflect.Float32, reflect.Float64:
 		return schema.Definition{
@@ -36,62 +133,28 @@ func typeDefinition(t reflect.Type) schema.Definition {
 		}
 
 	case reflect.Struct:
-		cs := codoc.GetStruct(t.String())
-		props := make([]schema.Property, 0, t.NumField())
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-
-			if !f.IsExported() {
-				continue
-			}
-
-			name := fieldName(f)
-			if name == "" {
-				continue
-			}
-
-			prop := schema.Property{
-				Name:       name,
-				Definition: typeDefinition(f.Type),
-			}
-
-			if cs != nil {
-				f := cs.Fields[f.Name]
-				if doc := f.Doc; doc != "" {
-					prop.Description = doc
-				} else {
-					prop.Description = f.Comment
-				}
-			}
-
-			props = append(props, prop)
-		}
-
-		return schema.Definition{
-			Type:       schema.Object,
-			Properties: props,
-		}
+		return c.structRef(t)
 
 	case reflect.Array, reflect.Slice:
-		td := typeDefinition(t.Elem())
-		return schema.Definition{
+		td := c.typeDefinition(t.Elem())
+		def := schema.Definition{
 			Type:  schema.Array,
 			Items: &td,
 		}
+		if t.Kind() == reflect.Array {
+			n := t.Len()
+			def.MinItems, def.MaxItems = &n, &n
+		}
+		return def
 
 	case reflect.Map:
 		if t.Key().Kind() != reflect.String {
 			panic("map keys must be strings")
 		}
 
-		if t.Elem().Kind() == reflect.Interface {
-			panic("map values cannot be interfaces")
-		}
-
-		td := typeDefinition(t.Elem())
 		return schema.Definition{
-			Type:  schema.Object,
-			Items: &td,
+			Type:                 schema.Object,
+			AdditionalProperties: schema.AdditionalSchema(c.typeDefinition(t.Elem())),
 		}
 
 	default:
@@ -100,13 +163,41 @@ func typeDefinition(t reflect.Type) schema.Definition {
 
 }
 
-func fieldName(f reflect.StructField) string {
-	var name string
-	if tag := f.Tag.Get("llm"); tag != "" {
-		name, _, _ = strings.Cut(tag, ",")
-	} else if tag := f.Tag.Get("json"); tag != "" {
-		name, _, _ = strings.Cut(tag, ",")
+func (c *typeDefs) structDefinition(t reflect.Type) schema.Definition {
+	fields := structFields(t)
+	props := make([]schema.Property, 0, len(fields))
+	for _, f := range fields {
+		prop := schema.Property{
+			Name:       f.name,
+			Definition: c.typeDefinition(f.Type),
+		}
+
+		if cs := codoc.GetStruct(f.owner.String()); cs != nil {
+			f := cs.Fields[f.Name]
+			if doc := f.Doc; doc != "" {
+				prop.Description = doc
+			} else {
+				prop.Description = f.Comment
+			}
+			prop.Description = docConstraints(&prop.Definition, prop.Description)
+		}
+		tagConstraints(&prop.Definition, f.StructField)
+
+		props = append(props, prop)
+	}
+
+	def := schema.Definition{
+		Type:       schema.Object,
+		Properties: props,
+	}
+	if c.strictSchema || c.closed {
+		def.AdditionalProperties = schema.AllowAdditional(false)
 	}
+	return def
+}
+
+func fieldName(f reflect.StructField) string {
+	name := tagName(f)
 	if name == "-" {
 		return ""
 	}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// union is an interface type whose implementations are told apart by the
+// value of a discriminator property.
+type union struct {
+	discriminator string
+	names         []string
+	types         []reflect.Type
+}
+
+// Union registers the implementations of the interface I, so it can be used
+// as an argument. Each implementation must be a struct, or a pointer to one,
+// and is selected by the value of the discriminator property, e.g.
+//
+//	tools.Union("kind", map[string]Shape{
+//		"circle": Circle{},
+//		"square": &Square{},
+//	})
+func Union[I any](discriminator string, variants map[string]I) Option {
+	t := reflect.TypeFor[I]()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("union type %s must be an interface", t))
+	}
+
+	u := &union{discriminator: discriminator}
+	for _, name := range slices.Sorted(maps.Keys(variants)) {
+		vt := reflect.TypeOf(variants[name])
+		if vt == nil {
+			panic(fmt.Sprintf("union %s variant %s is nil", t, name))
+		}
+		st := vt
+		if st.Kind() == reflect.Pointer {
+			st = st.Elem()
+		}
+		if st.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("union %s variant %s must be a struct", t, name))
+		}
+
+		u.names = append(u.names, name)
+		u.types = append(u.types, vt)
+	}
+
+	return func(c *config) { c.unions = withKey(c.unions, t, u) }
+}
+
+// unionRef returns the schema of the union t, inlined unless one of its
+// variants refers back to it, in which case it's put in $defs.
+func (c *typeDefs) unionRef(t reflect.Type, u *union) schema.Definition {
+	name, ok := c.refs[t]
+	if ok {
+		return schema.Definition{Ref: "#/$defs/" + name}
+	}
+	if c.visiting[t] {
+		// recursive, the outer call puts it in $defs
+		name = c.defName(t)
+		c.refs[t] = name
+		return schema.Definition{Ref: "#/$defs/" + name}
+	}
+
+	c.visiting[t] = true
+	def := c.unionDefinition(u)
+	delete(c.visiting, t)
+
+	if name, ok := c.refs[t]; ok {
+		c.defs[name] = def
+		return schema.Definition{Ref: "#/$defs/" + name}
+	}
+	return def
+}
+
+func (c *typeDefs) unionDefinition(u *union) schema.Definition {
+	def := schema.Definition{}
+	for i, vt := range u.types {
+		if vt.Kind() == reflect.Pointer {
+			vt = vt.Elem()
+		}
+		// inlined, as the discriminator is only part of the variant
+		vdef := c.structDefinition(vt)
+
+		tag := schema.Property{
+			Name:       u.discriminator,
+			Definition: schema.Definition{Type: schema.String, Const: u.names[i]},
+		}
+		idx := slices.IndexFunc(vdef.Properties, func(p schema.Property) bool {
+			return p.Name == u.discriminator
+		})
+		if idx >= 0 {
+			tag.Description = vdef.Properties[idx].Description
+			vdef.Properties[idx] = tag
+		} else {
+			vdef.Properties = append(schema.Properties{tag}, vdef.Properties...)
+		}
+		if !slices.Contains(vdef.Required, u.discriminator) {
+			vdef.Required = append([]string{u.discriminator}, vdef.Required...)
+		}
+
+		def.OneOf = append(def.OneOf, vdef)
+	}
+	return def
+}
+
+func (c *config) unionConverter(t reflect.Type, u *union) argConverter {
+	convs := make([]argConverter, len(u.types))
+	// whether the variant keeps the discriminator in one of its fields
+	keep := make([]bool, len(u.types))
+	for i, vt := range u.types {
+		convs[i] = c.converter(vt)
+
+		st := vt
+		if st.Kind() == reflect.Pointer {
+			st = st.Elem()
+		}
+		keep[i] = slices.ContainsFunc(structFields(st), func(f structField) bool {
+			return f.name == u.discriminator
+		})
+	}
+
+	return func(val any) (reflect.Value, error) {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return reflect.Value{}, typeError(t, val)
+		}
+
+		name, ok := m[u.discriminator].(string)
+		if !ok {
+			return reflect.Value{}, argError(pointerPath(u.discriminator), "missing discriminator")
+		}
+		i := slices.Index(u.names, name)
+		if i < 0 {
+			return reflect.Value{}, argError(pointerPath(u.discriminator), fmt.Sprintf("unknown variant %q", name))
+		}
+
+		if !keep[i] {
+			m = maps.Clone(m)
+			delete(m, u.discriminator)
+		}
+
+		v, err := convs[i](m)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		rv := reflect.New(t).Elem()
+		rv.Set(v)
+		return rv, nil
+	}
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/noonien/codoc"
+)
+
+type testExpr interface{ eval() float64 }
+
+type testLit struct {
+	Value float64 `json:"value"`
+}
+
+func (l testLit) eval() float64 { return l.Value }
+
+type testAdd struct {
+	L testExpr `json:"l"`
+	R testExpr `json:"r"`
+}
+
+func (a testAdd) eval() float64 { return a.L.eval() + a.R.eval() }
+
+func testEval(expr testExpr) float64 { return expr.eval() }
+
+func init() {
+	codoc.Register(codoc.Package{
+		ID:   "github.com/byte-sat/llum-tools/tools",
+		Name: "tools",
+		Functions: map[string]codoc.Function{
+			"testEval": {
+				Name: "testEval",
+				Doc:  "testEval evaluates an expression.",
+				Args: []string{"expr"},
+			},
+		},
+	})
+}
+
+func TestRecursiveUnion(t *testing.T) {
+	r, err := New(nil, Union("kind", map[string]testExpr{"lit": testLit{}, "add": testAdd{}}), testEval)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := r.Schema()[0].Parameters
+	if _, ok := params.Defs["testExpr"]; !ok {
+		t.Fatalf("recursive union not in $defs: %+v", params)
+	}
+
+	args := json.RawMessage(`{"expr": {"kind": "add", "l": {"kind": "lit", "value": 1}, "r": {"kind": "add", "l": {"kind": "lit", "value": 2}, "r": {"kind": "lit", "value": 3}}}}`)
+	out, err := r.InvokeJSON(nil, "testEval", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != 6.0 {
+		t.Fatalf("got %v, want 6", out)
+	}
+
+	_, err = r.InvokeJSON(nil, "testEval", json.RawMessage(`{"expr": {"kind": "add", "l": {"kind": "lit", "value": 1}, "r": {"kind": "mul"}}}`))
+	if err == nil {
+		t.Fatal("unknown variant accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// JSONSchemer is implemented by types which provide their own schema.
+type JSONSchemer interface {
+	JSONSchema() schema.Definition
+}
+
+var (
+	jsonSchemerType     = reflect.TypeFor[JSONSchemer]()
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// implements reports whether t or a pointer to it implements it.
+func implements(t, it reflect.Type) bool {
+	if t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface {
+		return false
+	}
+	return t.Implements(it) || reflect.PointerTo(t).Implements(it)
+}
+
+// unmarshalerDefinition returns the schema of types that implement
+// JSONSchemer or know how to parse themselves.
+func unmarshalerDefinition(t reflect.Type) (schema.Definition, bool) {
+	switch {
+	case implements(t, jsonSchemerType):
+		return reflect.New(t).Interface().(JSONSchemer).JSONSchema(), true
+	case implements(t, jsonUnmarshalerType):
+		// nothing is known about the JSON the type accepts
+		return schema.Definition{}, true
+	case implements(t, textUnmarshalerType):
+		return schema.Definition{Type: schema.String}, true
+	}
+	return schema.Definition{}, false
+}
+
+// unmarshalerConverter returns a converter for types implementing
+// json.Unmarshaler or encoding.TextUnmarshaler, or nil.
+func unmarshalerConverter(t reflect.Type) argConverter {
+	isJSON := implements(t, jsonUnmarshalerType)
+	isText := implements(t, textUnmarshalerType)
+	if !isJSON && !isText {
+		return nil
+	}
+
+	return func(val any) (reflect.Value, error) {
+		ptr := reflect.New(t)
+
+		if s, ok := val.(string); ok && isText {
+			if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return reflect.Value{}, err
+			}
+			return ptr.Elem(), nil
+		}
+
+		if !isJSON {
+			return reflect.Value{}, typeError(t, val)
+		}
+
+		b, err := json.Marshal(val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if err := ptr.Interface().(json.Unmarshaler).UnmarshalJSON(b); err != nil {
+			return reflect.Value{}, err
+		}
+		return ptr.Elem(), nil
+	}
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/byte-sat/llum-tools/schema"
+)
+
+// typeMapping maps a type which isn't represented by its kind in JSON to
+// its schema and converter.
+type typeMapping struct {
+	def  schema.Definition
+	conv argConverter
+}
+
+var ipDefinition = schema.Definition{
+	Type: schema.String,
+	AnyOf: []schema.Definition{
+		{Format: "ipv4"},
+		{Format: "ipv6"},
+	},
+}
+
+var wellKnown = map[reflect.Type]typeMapping{
+	reflect.TypeFor[time.Time](): {
+		def: schema.Definition{Type: schema.String, Format: "date-time"},
+		conv: parseString(func(s string) (any, error) {
+			return time.Parse(time.RFC3339Nano, s)
+		}),
+	},
+	reflect.TypeFor[time.Duration](): {
+		def: schema.Definition{
+			Type:        schema.String,
+			Description: `duration, e.g. "1h30m" or "500ms"`,
+			Pattern:     `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+		},
+		conv: parseString(func(s string) (any, error) {
+			return time.ParseDuration(s)
+		}),
+	},
+	reflect.TypeFor[net.IP](): {
+		def: ipDefinition,
+		conv: parseString(func(s string) (any, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", s)
+			}
+			return ip, nil
+		}),
+	},
+	reflect.TypeFor[netip.Addr](): {
+		def: ipDefinition,
+		conv: parseString(func(s string) (any, error) {
+			return netip.ParseAddr(s)
+		}),
+	},
+	reflect.TypeFor[url.URL](): {
+		def: schema.Definition{Type: schema.String, Format: "uri"},
+		conv: parseString(func(s string) (any, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+			return *u, nil
+		}),
+	},
+	reflect.TypeFor[[]byte](): {
+		def: schema.Definition{Type: schema.String, ContentEncoding: "base64"},
+		conv: parseString(func(s string) (any, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				// be forgiving about padding and the URL alphabet
+				b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(s), "="))
+			}
+			return b, err
+		}),
+	},
+	reflect.TypeFor[json.RawMessage](): {
+		def: schema.Definition{},
+		conv: func(val any) (reflect.Value, error) {
+			b, err := json.Marshal(val)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(json.RawMessage(b)), nil
+		},
+	},
+}
+
+var uuidDefinition = schema.Definition{Type: schema.String, Format: "uuid"}
+
+// wellKnownFor returns the well known type t, if it is one.
+func wellKnownFor(t reflect.Type) (typeMapping, bool) {
+	if wk, ok := wellKnown[t]; ok {
+		return wk, true
+	}
+
+	// UUID types of most libraries are 16 byte arrays
+	if t.Name() == "UUID" && t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8 {
+		return typeMapping{def: uuidDefinition, conv: parseUUID(t)}, true
+	}
+
+	return typeMapping{}, false
+}
+
+// parseString converts strings using parse.
+func parseString(parse func(string) (any, error)) argConverter {
+	return func(val any) (reflect.Value, error) {
+		s, ok := val.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected string, got %s", jsonType(val))
+		}
+
+		v, err := parse(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v), nil
+	}
+}
+
+func parseUUID(t reflect.Type) argConverter {
+	return func(val any) (reflect.Value, error) {
+		s, ok := val.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected string, got %s", jsonType(val))
+		}
+
+		s = strings.TrimPrefix(strings.Trim(s, "{}"), "urn:uuid:")
+		b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+		if err != nil || len(b) != 16 {
+			return reflect.Value{}, fmt.Errorf("invalid UUID: %s", val)
+		}
+
+		rv := reflect.New(t).Elem()
+		reflect.Copy(rv, reflect.ValueOf(b))
+		return rv, nil
+	}
+}
+
+// anyConverter accepts any value for empty interfaces.
+func anyConverter(t reflect.Type) argConverter {
+	return func(val any) (reflect.Value, error) {
+		rv := reflect.New(t).Elem()
+		if val != nil {
+			rv.Set(reflect.ValueOf(val))
+		}
+		return rv, nil
+	}
+}